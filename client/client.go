@@ -10,6 +10,8 @@ import (
 	"io"
 	"net"
 	"time"
+
+	jtt808 "github.com/sceneryback/jtt808/codec"
 )
 
 var host string
@@ -34,12 +36,14 @@ func main() {
 	}
 
 	go func() {
-		var allDataBytes []byte
-		var buff = make([]byte, 1)
-		var readStartTag = false
+		var fr = jtt808.NewFrameReader(conn, nil)
 		for {
-			_, err := conn.Read(buff)
+			frame, err := fr.ReadFrame()
 			if err != nil {
+				if _, ok := err.(*jtt808.FrameError); ok {
+					fmt.Printf("skipped frame: %s\n", err)
+					continue
+				}
 				if err == io.EOF {
 					fmt.Printf("EOF: %s\n", err)
 					return
@@ -47,23 +51,7 @@ func main() {
 				fmt.Printf("read failed: %s\n", err)
 				return
 			}
-			if !readStartTag {
-				// catch start
-				if buff[0] == 0x7e {
-					readStartTag = true
-				} else {
-					continue
-				}
-			} else {
-				// catch end
-				if buff[0] == 0x7e {
-					fmt.Printf("received jtt808 message: 7e%s7e\n", hex.EncodeToString(allDataBytes))
-					allDataBytes = allDataBytes[:0]
-					readStartTag = false
-					continue
-				}
-				allDataBytes = append(allDataBytes, buff[0])
-			}
+			fmt.Printf("received jtt808 message: %s\n", hex.EncodeToString(frame))
 		}
 	}()
 
@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"sync"
+)
+
+// AdditionalInfoDecoder decodes the body of a location additional info, i.e.
+// without its id and length. An error keeps the info as an UnknownInfo.
+type AdditionalInfoDecoder func(data []byte) (LocationAdditionalInfo, error)
+
+var defaultAdditionalInfoDecoders = map[uint8]AdditionalInfoDecoder{
+	InfoIdMileage:          decodeMileage,
+	InfoIdFuel:             decodeFuel,
+	InfoIdRecorderSpeed:    decodeRecorderSpeed,
+	InfoIdAlarmEventId:     decodeAlarmEventId,
+	InfoIdOverspeed:        decodeOverspeedInfo,
+	InfoIdArea:             decodeAreaInfo,
+	InfoIdRouteDrivingTime: decodeRouteDrivingTime,
+	InfoIdVehicleSignals:   decodeVehicleSignals,
+	InfoIdIOStatus:         decodeIOStatus,
+	InfoIdAnalog:           decodeAnalog,
+	InfoIdSignalStrength:   decodeSignalStrength,
+	InfoIdSatelliteCount:   decodeSatelliteCount,
+	0x54: func(data []byte) (LocationAdditionalInfo, error) {
+		return (&wifiCodec{}).Decode(data)
+	},
+	0x56: func(data []byte) (LocationAdditionalInfo, error) {
+		return (&batteryCodec{}).Decode(data)
+	},
+	0xef: func(data []byte) (LocationAdditionalInfo, error) {
+		return (&cellCodec{}).Decode(data)
+	},
+}
+
+// additionalInfoRegistry holds the decoders of a codec and the codecs derived
+// from it, vendor decoders take precedence over the ones for all vendors
+type additionalInfoRegistry struct {
+	mu       sync.RWMutex
+	decoders map[uint8]AdditionalInfoDecoder
+	vendors  map[string]map[uint8]AdditionalInfoDecoder
+}
+
+func newAdditionalInfoRegistry() *additionalInfoRegistry {
+	var r = &additionalInfoRegistry{
+		decoders: make(map[uint8]AdditionalInfoDecoder, len(defaultAdditionalInfoDecoders)),
+		vendors:  make(map[string]map[uint8]AdditionalInfoDecoder),
+	}
+	for id, d := range defaultAdditionalInfoDecoders {
+		r.decoders[id] = d
+	}
+	return r
+}
+
+func (r *additionalInfoRegistry) register(vendor string, id uint8, d AdditionalInfoDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if vendor == "" {
+		r.decoders[id] = d
+		return
+	}
+
+	decoders, ok := r.vendors[vendor]
+	if !ok {
+		decoders = make(map[uint8]AdditionalInfoDecoder)
+		r.vendors[vendor] = decoders
+	}
+	decoders[id] = d
+}
+
+// decoder returns nil if id has no decoder for vendor, a nil registry has the
+// default decoders only
+func (r *additionalInfoRegistry) decoder(vendor string, id uint8) AdditionalInfoDecoder {
+	if r == nil {
+		return defaultAdditionalInfoDecoders[id]
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if d, ok := r.vendors[vendor][id]; ok {
+		return d
+	}
+	return r.decoders[id]
+}
@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+type testFuelLevel struct {
+	Percentage uint8
+}
+
+func (f *testFuelLevel) Id() uint8 {
+	return 0xe1
+}
+
+func (f *testFuelLevel) Length() uint8 {
+	return 1
+}
+
+func (f *testFuelLevel) Info() []byte {
+	return []byte{f.Percentage}
+}
+
+func (f *testFuelLevel) Human() string {
+	return "fuel level\n"
+}
+
+func decodeTestFuelLevel(data []byte) (LocationAdditionalInfo, error) {
+	if len(data) != 1 {
+		return nil, ErrInfoLengthMismatch
+	}
+	return &testFuelLevel{Percentage: data[0]}, nil
+}
+
+func TestCodec_RegisterAdditionalInfoCodec(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdLocationReport, Phone: 19161017001},
+		B: &LocationMsgBody{
+			Basic: &BasicInfo{Timestamp: time.Date(2016, 10, 17, 10, 27, 56, 0, DefaultTimeZone)},
+			AdditionalInfos: []LocationAdditionalInfo{
+				NewUnknownInfo(0xe1, []byte{0x50}),
+				NewUnknownInfo(InfoIdSignalStrength, []byte{0x1f}),
+			},
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	infos := func(c Codec) []LocationAdditionalInfo {
+		msg, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		return msg.B.(*LocationMsgBody).AdditionalInfos
+	}
+
+	// vendor decoders are used by vendor codecs only
+	c.RegisterVendorAdditionalInfoCodec("70111", 0xe1, decodeTestFuelLevel)
+	c.RegisterVendorAdditionalInfoCodec("70111", InfoIdSignalStrength, nil)
+	assert.Equal(t, NewUnknownInfo(0xe1, []byte{0x50}), infos(c)[0])
+	assert.Equal(t, &SignalStrength{Value: 0x1f}, infos(c)[1])
+
+	vc := c.WithVendor("70111")
+	assert.Equal(t, &testFuelLevel{Percentage: 0x50}, infos(vc)[0])
+	assert.Equal(t, NewUnknownInfo(InfoIdSignalStrength, []byte{0x1f}), infos(vc)[1])
+	assert.Equal(t, NewUnknownInfo(0xe1, []byte{0x50}), infos(c.WithVendor("other"))[0])
+
+	// codecs derived earlier share the registrations
+	c.RegisterAdditionalInfoCodec(0xe1, decodeTestFuelLevel)
+	assert.Equal(t, &testFuelLevel{Percentage: 0x50}, infos(c)[0])
+	assert.Equal(t, &testFuelLevel{Percentage: 0x50}, infos(vc.WithTimeZone(time.UTC))[0])
+}
@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+var (
+	ErrBodyNotAuth = errors.New("body is not terminal auth")
+)
+
+const (
+	IMEILength            = 15
+	SoftwareVersionLength = 20
+)
+
+// AuthMsgBody is the terminal auth (0x0102) body
+type AuthMsgBody struct {
+	AuthCode string `json:"auth_code"`
+	// 2019 only
+	IMEI            string `json:"imei,omitempty"`
+	SoftwareVersion string `json:"software_version,omitempty"`
+}
+
+func (a *AuthMsgBody) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("auth code: %s\n", a.AuthCode))
+	if a.IMEI != "" || a.SoftwareVersion != "" {
+		buf.WriteString(fmt.Sprintf("imei: %s\n", a.IMEI))
+		buf.WriteString(fmt.Sprintf("software version: %s\n", a.SoftwareVersion))
+	}
+
+	return buf.String()
+}
+
+type authCodec struct {
+}
+
+// 0x0102
+func (c *authCodec) Encode(b Body) ([]byte, error) {
+	return c.EncodeVersion(b, Version2013)
+}
+
+func (c *authCodec) Decode(data []byte) (Body, error) {
+	return c.DecodeVersion(data, Version2013)
+}
+
+// 2013 bodies are the auth code only, 2019 ones prefix it with its length and
+// append the IMEI and software version
+func (c *authCodec) EncodeVersion(b Body, version string) ([]byte, error) {
+	a, ok := b.(*AuthMsgBody)
+	if !ok {
+		return nil, ErrBodyNotAuth
+	}
+
+	authCode, err := utils.EncodeGBK(a.AuthCode)
+	if err != nil {
+		return nil, err
+	}
+	if version != Version2019 {
+		return authCode, nil
+	}
+
+	if len(authCode) > 0xff {
+		return nil, ErrFieldTooLong
+	}
+
+	var res = make([]byte, 0, 1+len(authCode)+IMEILength+SoftwareVersionLength)
+	res = append(res, uint8(len(authCode)))
+	res = append(res, authCode...)
+
+	res, err = appendFixedString(res, a.IMEI, IMEILength)
+	if err != nil {
+		return nil, err
+	}
+	return appendFixedString(res, a.SoftwareVersion, SoftwareVersionLength)
+}
+
+func (c *authCodec) DecodeVersion(data []byte, version string) (Body, error) {
+	if version != Version2019 {
+		authCode, err := utils.DecodeGBK(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &AuthMsgBody{AuthCode: authCode}, nil
+	}
+
+	br := newBodyReader(data)
+	authCodeBytes := br.bytes(int(br.byte()))
+	imeiBytes := br.bytes(IMEILength)
+	softwareVersionBytes := br.bytes(SoftwareVersionLength)
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	var a AuthMsgBody
+	var err error
+	// the auth code has its length, it is not padded
+	a.AuthCode, err = utils.DecodeGBK(authCodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		bs []byte
+		s  *string
+	}{
+		{imeiBytes, &a.IMEI},
+		{softwareVersionBytes, &a.SoftwareVersion},
+	} {
+		*f.s, err = decodeFixedString(f.bs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,119 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrBodyNotBatchLocation = errors.New("body is not batch location report")
+)
+
+// batch location data types
+const (
+	BatchLocationNormal   uint8 = 0
+	BatchLocationBackfill uint8 = 1
+)
+
+// BatchLocationBody is the batch location report (0x0704) of positions a
+// terminal buffered, e.g. while offline
+type BatchLocationBody struct {
+	// BatchLocationNormal or BatchLocationBackfill
+	Type  uint8              `json:"type"`
+	Items []*LocationMsgBody `json:"items"`
+}
+
+func (b *BatchLocationBody) Human() string {
+	var buf bytes.Buffer
+
+	var t = "normal"
+	if b.Type == BatchLocationBackfill {
+		t = "backfill"
+	}
+
+	buf.WriteString(fmt.Sprintf("type: %s\n", t))
+	buf.WriteString(fmt.Sprintf("items: %d\n", len(b.Items)))
+	for i := range b.Items {
+		buf.WriteString(fmt.Sprintf("item %d ===== \n", i))
+		buf.WriteString(b.Items[i].Human())
+	}
+
+	return buf.String()
+}
+
+// batchLocationCodec encodes every item, prefixed with its WORD length, with
+// the location report codec
+type batchLocationCodec struct {
+	location locationCodec
+}
+
+// 0x0704
+func (c *batchLocationCodec) Encode(b Body) ([]byte, error) {
+	body, ok := b.(*BatchLocationBody)
+	if !ok {
+		return nil, ErrBodyNotBatchLocation
+	}
+	if len(body.Items) > 0xffff {
+		return nil, ErrFieldTooLong
+	}
+
+	var res = appendWord(nil, uint16(len(body.Items)))
+	res = append(res, body.Type)
+
+	for i := range body.Items {
+		item, err := c.location.Encode(body.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(item) > 0xffff {
+			return nil, ErrFieldTooLong
+		}
+		res = appendWord(res, uint16(len(item)))
+		res = append(res, item...)
+	}
+
+	return res, nil
+}
+
+// Decode marks the items of a backfill batch as backfilled
+func (c *batchLocationCodec) Decode(data []byte) (Body, error) {
+	var body BatchLocationBody
+
+	br := newBodyReader(data)
+	count := int(br.word())
+	body.Type = br.byte()
+
+	for i := 0; i < count && br.err == nil; i++ {
+		itemBytes := br.bytes(int(br.word()))
+		if br.err != nil {
+			break
+		}
+
+		item, err := c.location.Decode(itemBytes)
+		if err != nil {
+			return nil, shiftOffset(err, br.off-len(itemBytes))
+		}
+		location := item.(*LocationMsgBody)
+		location.Backfilled = body.Type == BatchLocationBackfill
+		body.Items = append(body.Items, location)
+	}
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	return &body, nil
+}
+
+func (c *batchLocationCodec) withTimeZone(loc *time.Location) BodyCodec {
+	var n = *c
+	n.location.basic.loc = loc
+	return &n
+}
+
+func (c *batchLocationCodec) withVendor(vendor string) BodyCodec {
+	var n = *c
+	n.location.ai.vendor = vendor
+	return &n
+}
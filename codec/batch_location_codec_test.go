@@ -0,0 +1,55 @@
+package codec
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestBatchLocationCodec_RoundTrip(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var item = func(ts time.Time, speed uint16) *LocationMsgBody {
+		return &LocationMsgBody{
+			Basic: &BasicInfo{
+				Latitude:  22543096,
+				Longitude: 114057865,
+				Speed:     speed,
+				Timestamp: ts,
+			},
+			AdditionalInfos: []LocationAdditionalInfo{&Mileage{Value: 1000}},
+		}
+	}
+	var ts = time.Date(2016, 10, 17, 10, 27, 56, 0, DefaultTimeZone)
+	var body = &BatchLocationBody{
+		Type:  BatchLocationBackfill,
+		Items: []*LocationMsgBody{item(ts, 600), item(ts.Add(time.Minute), 0)},
+	}
+
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdBatchLocationReport, Phone: 19161017001},
+		B: body,
+	})
+	assert.Equal(t, nil, err)
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	decoded := msg.B.(*BatchLocationBody)
+	assert.Equal(t, BatchLocationBackfill, decoded.Type)
+	assert.Equal(t, 2, len(decoded.Items))
+	for i := range decoded.Items {
+		assert.Equal(t, true, decoded.Items[i].Backfilled)
+		assert.Equal(t, body.Items[i].Basic, decoded.Items[i].Basic)
+		assert.Equal(t, body.Items[i].AdditionalInfos, decoded.Items[i].AdditionalInfos)
+	}
+
+	// items follow the codec's time zone
+	msg, err = c.WithTimeZone(time.UTC).Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ts.Add(8*time.Hour).Unix(), msg.B.(*BatchLocationBody).Items[0].Basic.Timestamp.Unix())
+}
+
+func TestBatchLocationCodec_Truncated(t *testing.T) {
+	_, err := (&batchLocationCodec{}).Decode([]byte{0x00, 0x02, 0x00, 0x00, 0x1c, 0x00})
+	assert.NotEqual(t, nil, err)
+}
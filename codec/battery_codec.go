@@ -6,9 +6,10 @@ import (
 )
 
 type Battery struct {
-	Percentage uint8
-	Extention  uint8
-	Raw        []byte
+	Percentage uint8 `json:"percentage"`
+	Extention  uint8 `json:"extension"`
+	// decoded bytes, Info() is built from the fields above
+	Raw []byte `json:"-"`
 }
 
 func (b *Battery) Id() uint8 {
@@ -16,11 +17,11 @@ func (b *Battery) Id() uint8 {
 }
 
 func (b *Battery) Length() uint8 {
-	return 3
+	return uint8(len(b.Info()))
 }
 
 func (b *Battery) Info() []byte {
-	return b.Raw
+	return []byte{b.Percentage, b.Extention}
 }
 
 func (b *Battery) Human() string {
@@ -35,6 +36,12 @@ type batteryCodec struct {
 }
 
 func (w *batteryCodec) Decode(data []byte) (*Battery, error) {
+	if len(data) < 2 {
+		return nil, &DecodeError{Offset: len(data), Err: ErrTruncated}
+	}
+	if len(data) > 2 {
+		return nil, ErrInfoLengthMismatch
+	}
 	return &Battery{
 		Percentage: uint8(data[0]),
 		Extention:  uint8(data[1]),
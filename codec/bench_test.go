@@ -0,0 +1,78 @@
+package codec
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func BenchmarkCodec_Decode(b *testing.B) {
+	var c, _ = NewCodec(nil)
+	data, _ := hex.DecodeString(sampleLocationFrame)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodec_Encode(b *testing.B) {
+	var c, _ = NewCodec(nil)
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	msg, _ := c.Decode(data)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCodec_DecodeInto decodes into the same message and frame buffer,
+// as a connection reading frame after frame would. The allocations left are
+// those of the body.
+func BenchmarkCodec_DecodeInto(b *testing.B) {
+	var c, _ = NewCodec(nil)
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	var frame = make([]byte, len(data))
+	var msg Message
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		copy(frame, data)
+		if err := c.DecodeInto(&msg, frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBodyCodecs_Encode encodes the bodies of the other frequent
+// messages
+func BenchmarkBodyCodecs_Encode(b *testing.B) {
+	var bodies = []struct {
+		bc   BodyCodec
+		body Body
+	}{
+		{&terminalResponseCodec{}, &TerminalResponse{SerialNum: 7, ID: MessageIdSetParams}},
+		{&registerResponseCodec{}, &RegisterResponse{SerialNum: 3, AuthCode: "a1b2c3"}},
+		{&paramsResponseCodec{}, &ParamsResponse{SerialNum: 3, Params: []*Param{
+			{Id: ParamIdHeartbeatInterval, Value: uint32(30)},
+			{Id: ParamIdFenceRadius, Value: uint16(500)},
+		}}},
+		{&retransmissionRequestCodec{}, &RetransmissionRequest{SerialNum: 3, SegmentNums: []uint16{2, 3}}},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, c := range bodies {
+			if _, err := c.bc.Encode(c.body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownCellLayout = errors.New("base station list has unknown layout")
+)
+
+const cellLength = 20
+
+type Cell struct {
+	MCC    uint16 `json:"mcc"`
+	MNC    uint16 `json:"mnc"`
+	LAC    uint16 `json:"lac"`
+	CellId uint16 `json:"cell_id"`
+	// dBm
+	RSSI int16 `json:"rssi"`
+}
+
+// AdditionalInfoCells is the vendor base station list (0xEF): two vendor
+// specific bytes, the cell count, then 20 bytes per cell. Every cell is MCC,
+// MNC, LAC and cell id as WORDs, 4 reserved bytes, RSSI as a signed WORD and
+// 6 more reserved bytes.
+type AdditionalInfoCells struct {
+	// vendor specific, meaning unknown
+	Prefix uint16  `json:"prefix"`
+	Cells  []*Cell `json:"cells"`
+	// decoded bytes, Info() is built from Prefix and Cells with zero reserved
+	// bytes
+	Raw []byte `json:"-"`
+}
+
+func (a *AdditionalInfoCells) Id() uint8 {
+	return uint8(0xef)
+}
+
+func (a *AdditionalInfoCells) Length() uint8 {
+	return uint8(len(a.Cells)*cellLength + 3)
+}
+
+func (a *AdditionalInfoCells) Info() []byte {
+	var info = make([]byte, 3, int(a.Length()))
+	binary.BigEndian.PutUint16(info, a.Prefix)
+	info[2] = uint8(len(a.Cells))
+
+	for i := range a.Cells {
+		var cell = make([]byte, cellLength)
+		binary.BigEndian.PutUint16(cell[0:], a.Cells[i].MCC)
+		binary.BigEndian.PutUint16(cell[2:], a.Cells[i].MNC)
+		binary.BigEndian.PutUint16(cell[4:], a.Cells[i].LAC)
+		binary.BigEndian.PutUint16(cell[6:], a.Cells[i].CellId)
+		binary.BigEndian.PutUint16(cell[12:], uint16(a.Cells[i].RSSI))
+		info = append(info, cell...)
+	}
+	return info
+}
+
+func (a *AdditionalInfoCells) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("Cell list:\n")
+
+	for _, c := range a.Cells {
+		buf.WriteString(fmt.Sprintf("mcc %d mnc %d lac %d ci %d rssi %d\n", c.MCC, c.MNC, c.LAC, c.CellId, c.RSSI))
+	}
+
+	return buf.String()
+}
+
+type cellCodec struct {
+}
+
+// Decode fails on any other layout, including non-zero reserved bytes, the
+// info is then kept unknown
+func (c *cellCodec) Decode(data []byte) (*AdditionalInfoCells, error) {
+	if len(data) < 3 || len(data) != 3+int(data[2])*cellLength {
+		return nil, ErrInfoLengthMismatch
+	}
+
+	var cells = AdditionalInfoCells{
+		Prefix: binary.BigEndian.Uint16(data),
+		Raw:    data,
+	}
+
+	cellsNum := int(data[2])
+	data = data[3:]
+
+	// one allocation for all the cells
+	var list = make([]Cell, cellsNum)
+	cells.Cells = make([]*Cell, cellsNum)
+	for i := range list {
+		cell := data[i*cellLength : (i+1)*cellLength]
+		if !zeroed(cell[8:12]) || !zeroed(cell[14:]) {
+			return nil, ErrUnknownCellLayout
+		}
+		list[i] = Cell{
+			MCC:    binary.BigEndian.Uint16(cell[0:]),
+			MNC:    binary.BigEndian.Uint16(cell[2:]),
+			LAC:    binary.BigEndian.Uint16(cell[4:]),
+			CellId: binary.BigEndian.Uint16(cell[6:]),
+			RSSI:   int16(binary.BigEndian.Uint16(cell[12:])),
+		}
+		cells.Cells[i] = &list[i]
+	}
+
+	return &cells, nil
+}
+
+func zeroed(bs []byte) bool {
+	for _, b := range bs {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
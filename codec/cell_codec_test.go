@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"encoding/hex"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestCellCodec_Decode(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+
+	var cells *AdditionalInfoCells
+	for _, info := range msg.B.(*LocationMsgBody).AdditionalInfos {
+		if info.Id() == 0xef {
+			cells = info.(*AdditionalInfoCells)
+		}
+	}
+	assert.T(t, cells != nil)
+	assert.Equal(t, 6, len(cells.Cells))
+	assert.Equal(t, &Cell{MCC: 460, MNC: 0, LAC: 0x2490, CellId: 0x0e61, RSSI: -86}, cells.Cells[0])
+	assert.Equal(t, &Cell{MCC: 460, MNC: 0, LAC: 0x2490, CellId: 0x1145, RSSI: -102}, cells.Cells[5])
+	assert.Equal(t, cells.Raw, cells.Info())
+	assert.Equal(t, int(cells.Length()), len(cells.Info()))
+}
+
+func TestCellCodec_LengthMismatch(t *testing.T) {
+	_, err := (&cellCodec{}).Decode([]byte{0xe6, 0x0a, 0x02, 0x01, 0xcc})
+	assert.Equal(t, ErrInfoLengthMismatch, err)
+}
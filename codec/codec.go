@@ -1,20 +1,100 @@
 package codec
 
 import (
-	"bytes"
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
 var (
 	ErrChecksumFailed        = errors.New("failed to verify checksum")
 	ErrDecodeHeaderFailed    = errors.New("failed to decode header")
+	ErrPhoneOutOfRange       = errors.New("phone exceeds the max uint64")
 	ErrMessageIdNotSupported = errors.New("message id not supported yet")
+	ErrVersionNotSupported   = errors.New("protocol version not supported")
+	ErrBodyTooLong           = errors.New("body exceeds max body length, encode it segmented")
+	ErrTruncated             = errors.New("data truncated")
+	ErrBodyLengthMismatch    = errors.New("body length does not match header")
+	ErrInvalidEscape         = errors.New("invalid escape sequence")
+	ErrTrailingData          = errors.New("data left after the body")
 )
 
+// DecodeError is where decoding failed. Offsets are relative to the unescaped
+// frame without its leading 0x7e, or to the body for DecodeBody, and to the
+// escaped frame for ErrInvalidEscape.
+type DecodeError struct {
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// shiftOffset moves the offset of a *DecodeError by off, e.g. from a field
+// to the body containing it
+func shiftOffset(err error, off int) error {
+	if de, ok := err.(*DecodeError); ok {
+		return &DecodeError{Offset: de.Offset + off, Err: de.Err}
+	}
+	return err
+}
+
+// Codec is safe for concurrent use: body and additional info codecs may be
+// registered while other goroutines encode and decode with it or with codecs
+// derived from it.
 type Codec interface {
 	Encode(*Message) ([]byte, error)
+	// Decode returns the body of a segment as a *RawBody, segments are decoded
+	// once reassembled, see Reassembler. Malformed frames fail with a
+	// *DecodeError of ErrTruncated, ErrTrailingData, ErrBodyLengthMismatch or
+	// ErrInvalidEscape.
 	Decode([]byte) (*Message, error)
+
+	// DecodeInto is Decode reusing msg.H, including its Attr and SegInfo, for
+	// hot paths. It saves the frame copy and header allocations only, bodies
+	// are allocated as by Decode. It unescapes data in place, so data is
+	// modified and byte fields of the body, e.g. of a *RawBody, point into
+	// it. msg is left partially overwritten on errors.
+	DecodeInto(msg *Message, data []byte) error
+
+	// EncodeSegmented splits a body longer than maxBodyLen, at most and by
+	// default MaxBodyLength, into segments with consecutive serial numbers
+	// starting at the header's. Shorter bodies are encoded as one frame.
+	EncodeSegmented(msg *Message, maxBodyLen int) ([][]byte, error)
+
+	// DecodeBody decodes an unescaped body of message header h
+	DecodeBody(h *Header, data []byte) (Body, error)
+
+	// RegisterBodyCodec sets the body codec used for message id, replacing
+	// any codec previously registered for it. Messages whose id has no codec
+	// are decoded into a *RawBody.
+	RegisterBodyCodec(id uint16, bc BodyCodec)
+
+	// WithTimeZone returns a codec reading and writing BCD timestamps in loc,
+	// e.g. for one session. It starts with the body codecs registered so far.
+	WithTimeZone(loc *time.Location) Codec
+
+	// RegisterAdditionalInfoCodec sets the decoder of location additional
+	// info id for all vendors. Infos whose id has no decoder are decoded into
+	// an *UnknownInfo.
+	RegisterAdditionalInfoCodec(id uint8, decoder AdditionalInfoDecoder)
+
+	// RegisterVendorAdditionalInfoCodec sets the decoder of additional info id
+	// used by codecs returned by WithVendor(vendor), taking precedence over
+	// the one for all vendors. A nil decoder keeps id unknown for the vendor.
+	RegisterVendorAdditionalInfoCodec(vendor string, id uint8, decoder AdditionalInfoDecoder)
+
+	// WithVendor returns a codec decoding additional infos with the decoders
+	// of vendor, e.g. the manufacturer id of a terminal. It starts with the
+	// body codecs registered so far and shares additional info decoders with
+	// this codec.
+	WithVendor(vendor string) Codec
 }
 
 type HeaderCodec interface {
@@ -27,51 +107,284 @@ type BodyCodec interface {
 	Decode([]byte) (Body, error)
 }
 
+// VersionedBodyCodec is implemented by body codecs whose layout differs
+// between protocol versions, the codec then calls it with the header version
+// instead of Encode and Decode.
+type VersionedBodyCodec interface {
+	BodyCodec
+	EncodeVersion(b Body, version string) ([]byte, error)
+	DecodeVersion(data []byte, version string) (Body, error)
+}
+
 type CodecConfig struct {
+	// VersionAuto (default), Version2013 or Version2019. Headers are decoded
+	// as this version, and encoded as it unless the header sets one.
 	Version string
+	// TimeZone of device BCD timestamps, DefaultTimeZone if nil
+	TimeZone *time.Location
+}
+
+// timeZoneCodec is implemented by body codecs with BCD timestamps
+type timeZoneCodec interface {
+	withTimeZone(loc *time.Location) BodyCodec
+}
+
+// vendorCodec is implemented by body codecs with additional infos
+type vendorCodec interface {
+	withVendor(vendor string) BodyCodec
 }
 
 type codec struct {
 	header HeaderCodec
-	body   BodyCodec
+	// mu guards bodies, encoding and decoding hold no other state
+	mu     sync.RWMutex
+	bodies map[uint16]BodyCodec
+	infos  *additionalInfoRegistry
 }
 
 func NewCodec(cfg *CodecConfig) (Codec, error) {
-	return &codec{
-		header: &headerCodec{},
-	}, nil
+	if cfg == nil {
+		cfg = &CodecConfig{}
+	}
+	switch cfg.Version {
+	case VersionAuto, Version2013, Version2019:
+	default:
+		return nil, ErrVersionNotSupported
+	}
+
+	c := &codec{
+		header: &headerCodec{version: cfg.Version},
+		bodies: make(map[uint16]BodyCodec),
+		infos:  newAdditionalInfoRegistry(),
+	}
+
+	location := locationCodec{
+		basic: locationBasicInfoCodec{loc: cfg.TimeZone},
+		ai:    locationAdditionalInfoCodec{registry: c.infos},
+	}
+
+	c.RegisterBodyCodec(MessageIdTerminalResponse, &terminalResponseCodec{})
+	c.RegisterBodyCodec(MessageIdHeartbeat, &heartbeatCodec{})
+	c.RegisterBodyCodec(MessageIdTerminalRetransmissionRequest, &retransmissionRequestCodec{})
+	c.RegisterBodyCodec(MessageIdTerminalRegister, &registerCodec{})
+	c.RegisterBodyCodec(MessageIdTerminalAuth, &authCodec{})
+	c.RegisterBodyCodec(MessageIdParamsResponse, &paramsResponseCodec{})
+	c.RegisterBodyCodec(MessageIdLocationReport, &location)
+	c.RegisterBodyCodec(MessageIdLocationQueryResponse, &locationQueryResponseCodec{location: location})
+	c.RegisterBodyCodec(MessageIdBatchLocationReport, &batchLocationCodec{location: location})
+	c.RegisterBodyCodec(MessageIdServerResponse, &responseCodec{})
+	c.RegisterBodyCodec(MessageIdRetransmissionRequest, &retransmissionRequestCodec{})
+	c.RegisterBodyCodec(MessageIdRegisterResponse, &registerResponseCodec{})
+	c.RegisterBodyCodec(MessageIdSetParams, &setParamsCodec{})
+	c.RegisterBodyCodec(MessageIdQueryParams, &queryParamsCodec{})
+	c.RegisterBodyCodec(MessageIdTerminalControl, &terminalControlCodec{})
+	c.RegisterBodyCodec(MessageIdQuerySpecificParams, &querySpecificParamsCodec{})
+	c.RegisterBodyCodec(MessageIdLocationQuery, &locationQueryCodec{})
+	c.RegisterBodyCodec(MessageIdTemporaryTracking, &temporaryTrackingCodec{})
+
+	return c, nil
 }
 
-func (c *codec) Encode(msg *Message) ([]byte, error) {
-	var buf bytes.Buffer
+func (c *codec) RegisterBodyCodec(id uint16, bc BodyCodec) {
+	c.mu.Lock()
+	c.bodies[id] = bc
+	c.mu.Unlock()
+}
+
+func (c *codec) bodyCodec(id uint16) (BodyCodec, bool) {
+	c.mu.RLock()
+	bc, ok := c.bodies[id]
+	c.mu.RUnlock()
+	return bc, ok
+}
+
+func (c *codec) RegisterAdditionalInfoCodec(id uint8, decoder AdditionalInfoDecoder) {
+	c.infos.register("", id, decoder)
+}
+
+func (c *codec) RegisterVendorAdditionalInfoCodec(vendor string, id uint8, decoder AdditionalInfoDecoder) {
+	c.infos.register(vendor, id, decoder)
+}
+
+func (c *codec) WithTimeZone(loc *time.Location) Codec {
+	return c.derive(func(bc BodyCodec) BodyCodec {
+		if tz, ok := bc.(timeZoneCodec); ok {
+			return tz.withTimeZone(loc)
+		}
+		return bc
+	})
+}
+
+func (c *codec) WithVendor(vendor string) Codec {
+	return c.derive(func(bc BodyCodec) BodyCodec {
+		if vc, ok := bc.(vendorCodec); ok {
+			return vc.withVendor(vendor)
+		}
+		return bc
+	})
+}
+
+// derive copies c with every body codec passed through f
+func (c *codec) derive(f func(BodyCodec) BodyCodec) *codec {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	headerBytes, err := c.header.Encode(msg.H)
+	var n = &codec{
+		header: c.header,
+		bodies: make(map[uint16]BodyCodec, len(c.bodies)),
+		infos:  c.infos,
+	}
+	for id, bc := range c.bodies {
+		n.bodies[id] = f(bc)
+	}
+	return n
+}
+
+func (c *codec) encodeBody(h *Header, b Body) ([]byte, error) {
+	// raw bodies are passed through as is, whatever the message id
+	if raw, ok := b.(*RawBody); ok {
+		return raw.Data, nil
+	}
+
+	bc, ok := c.bodyCodec(h.MessageId)
+	if !ok {
+		return nil, ErrMessageIdNotSupported
+	}
+	if vbc, ok := bc.(VersionedBodyCodec); ok {
+		return vbc.EncodeVersion(b, c.version(h))
+	}
+	return bc.Encode(b)
+}
+
+func (c *codec) DecodeBody(h *Header, data []byte) (Body, error) {
+	bc, ok := c.bodyCodec(h.MessageId)
+	if !ok {
+		return &RawBody{Data: data}, nil
+	}
+	if vbc, ok := bc.(VersionedBodyCodec); ok {
+		return vbc.DecodeVersion(data, c.version(h))
+	}
+	return bc.Decode(data)
+}
+
+// version returns the version h is encoded with
+func (c *codec) version(h *Header) string {
+	if h.Version != VersionAuto {
+		return h.Version
+	}
+	if hc, ok := c.header.(*headerCodec); ok && hc.version != VersionAuto {
+		return hc.version
+	}
+	return Version2013
+}
+
+func (c *codec) Encode(msg *Message) ([]byte, error) {
+	bodyBytes, err := c.encodeBody(msg.H, msg.B)
 	if err != nil {
 		return nil, err
 	}
+	if len(bodyBytes) > MaxBodyLength {
+		return nil, ErrBodyTooLong
+	}
 
-	buf.Write(headerBytes)
+	return c.encodeFrame(msg.H, bodyBytes)
+}
 
-	switch msg.H.MessageId {
-	case 0x8001:
-		c.body = &responseCodec{}
-	default:
-		return nil, ErrMessageIdNotSupported
+func (c *codec) EncodeSegmented(msg *Message, maxBodyLen int) ([][]byte, error) {
+	if maxBodyLen <= 0 || maxBodyLen > MaxBodyLength {
+		maxBodyLen = MaxBodyLength
 	}
 
-	bodyBytes, err := c.body.Encode(msg.B)
+	bodyBytes, err := c.encodeBody(msg.H, msg.B)
 	if err != nil {
 		return nil, err
 	}
 
-	buf.Write(bodyBytes)
+	if len(bodyBytes) <= maxBodyLen {
+		frame, err := c.encodeFrame(msg.H, bodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{frame}, nil
+	}
+
+	var total = (len(bodyBytes) + maxBodyLen - 1) / maxBodyLen
+	if total > 0xffff {
+		return nil, ErrBodyTooLong
+	}
+
+	var frames = make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * maxBodyLen
+		if end > len(bodyBytes) {
+			end = len(bodyBytes)
+		}
+
+		var header = *msg.H
+		var attr BodyAttr
+		if header.Attr != nil {
+			attr = *header.Attr
+		}
+		attr.SegmentationEnabled = true
+		header.Attr = &attr
+		header.SerialNum = msg.H.SerialNum + uint16(i)
+		header.SegInfo = &SegmentInfo{
+			TotalSegments: uint16(total),
+			SegmentNum:    uint16(i + 1),
+		}
+
+		frame, err := c.encodeFrame(&header, bodyBytes[i*maxBodyLen:end])
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
 
-	headerBodyBytes := buf.Bytes()
+	return frames, nil
+}
 
-	buf.WriteByte(c.checksum(headerBodyBytes))
+// frameBuffers hold unescaped header, body and checksum while encoding
+var frameBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, MessageHeaderMaxLength2019+MaxBodyLength+1)
+		return &buf
+	},
+}
 
-	var res = []byte{0x7e}
-	res = append(res, c.escape(buf.Bytes())...)
+func (c *codec) encodeFrame(h *Header, bodyBytes []byte) ([]byte, error) {
+	// body length always follows the encoded body, the caller's header is left untouched
+	var header = *h
+	var attr BodyAttr
+	if header.Attr != nil {
+		attr = *header.Attr
+	}
+	attr.BodyLength = uint16(len(bodyBytes))
+	header.Attr = &attr
+	header.Version = c.version(&header)
+
+	bufp := frameBuffers.Get().(*[]byte)
+	defer frameBuffers.Put(bufp)
+
+	var buf = (*bufp)[:0]
+	var err error
+	if hc, ok := c.header.(*headerCodec); ok {
+		buf, err = hc.appendHeader(buf, &header)
+	} else {
+		var headerBytes []byte
+		headerBytes, err = c.header.Encode(&header)
+		buf = append(buf, headerBytes...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf = append(buf, bodyBytes...)
+	buf = append(buf, c.checksum(buf))
+	*bufp = buf
+
+	var res = make([]byte, 0, escapedLength(buf)+2)
+	res = append(res, 0x7e)
+	res = appendEscaped(res, buf)
 	res = append(res, 0x7e)
 
 	return res, nil
@@ -83,44 +396,71 @@ rules：
 0x7d => 0x7d01
 */
 func (*codec) escape(data []byte) []byte {
-	var result []byte
+	return appendEscaped(make([]byte, 0, escapedLength(data)), data)
+}
+
+func escapedLength(data []byte) int {
 	var l = len(data)
-	// escape
-	for i := 0; i < l; i++ {
-		if data[i] == 0x7e {
-			result = append(result, 0x7d, 0x02)
-		} else if data[i] == 0x7d {
-			result = append(result, 0x7d, 0x01)
-		} else {
-			result = append(result, data[i])
+	for _, b := range data {
+		if b == 0x7e || b == 0x7d {
+			l++
+		}
+	}
+	return l
+}
+
+func appendEscaped(dst []byte, data []byte) []byte {
+	for _, b := range data {
+		switch b {
+		case 0x7e:
+			dst = append(dst, 0x7d, 0x02)
+		case 0x7d:
+			dst = append(dst, 0x7d, 0x01)
+		default:
+			dst = append(dst, b)
 		}
 	}
-	return result
+	return dst
 }
 
-func (*codec) unescape(data []byte) []byte {
-	var result []byte
+func (*codec) unescape(data []byte) ([]byte, error) {
+	var result = make([]byte, len(data))
+	copy(result, data)
+	return unescapeInPlace(result)
+}
+
+// unescapeInPlace restores data over itself, unescaping never grows it
+func unescapeInPlace(data []byte) ([]byte, error) {
+	var w int
 	var l = len(data)
-	// unescape, i.e. restore
-	for i := 0; i < l; i++ {
-		if data[i] == 0x7d && data[i+1] == 0x2 {
-			result = append(result, 0x7e)
-			i++
-		} else if data[i] == 0x7d && data[i+1] == 0x1 {
-			result = append(result, 0x7d)
-			i++
-		} else {
-			result = append(result, data[i])
+	for r := 0; r < l; r++ {
+		if data[r] != 0x7d {
+			data[w] = data[r]
+			w++
+			continue
+		}
+		if r+1 >= l {
+			return nil, &DecodeError{Offset: r, Err: ErrInvalidEscape}
 		}
+		switch data[r+1] {
+		case 0x2:
+			data[w] = 0x7e
+		case 0x1:
+			data[w] = 0x7d
+		default:
+			return nil, &DecodeError{Offset: r, Err: ErrInvalidEscape}
+		}
+		w++
+		r++
 	}
-	return result
+	return data[:w], nil
 }
 
 // headerBodyBytes Contains original unescaped header and body bytes
 func (c *codec) checksum(headerBodyBytes []byte) byte {
-	var realsum = headerBodyBytes[0]
+	var realsum byte
 	var msgLength = len(headerBodyBytes)
-	for i := 1; i < msgLength; i++ {
+	for i := 0; i < msgLength; i++ {
 		realsum = realsum ^ headerBodyBytes[i]
 	}
 	return realsum
@@ -129,6 +469,9 @@ func (c *codec) checksum(headerBodyBytes []byte) byte {
 // unescaped(header+body+checksum)
 func (c *codec) checksumVerified(unescapedMsg []byte) bool {
 	unescapedLength := len(unescapedMsg)
+	if unescapedLength < 2 {
+		return false
+	}
 
 	var checksum = unescapedMsg[unescapedLength-1:][0]
 	realsum := c.checksum(unescapedMsg[0 : unescapedLength-1])
@@ -136,104 +479,90 @@ func (c *codec) checksumVerified(unescapedMsg []byte) bool {
 	return realsum == checksum
 }
 
-func (c *codec) decodeHeader(h []byte) (*Header, error) {
-	var header Header
-
-	var msgIdBytes = h[:2]
-	var msgAttrBytes = h[2:4]
-	var phoneBytes = h[4:10]
-	var serialNumBytes = h[10:12]
-
-	err := binary.Read(bytes.NewReader(msgIdBytes), binary.BigEndian, &header.MessageId)
-	if err != nil {
-		return nil, err
-	}
-
-	err = binary.Read(bytes.NewReader(phoneBytes), binary.BigEndian, &header.Phone)
-	if err != nil {
-		return nil, err
-	}
-
-	err = binary.Read(bytes.NewReader(serialNumBytes), binary.BigEndian, &header.SerialNum)
-	if err != nil {
-		return nil, err
-	}
-
-	header.Attr = &BodyAttr{}
-	header.Attr.Preserved = uint8(msgAttrBytes[0] >> 6)
-	if msgAttrBytes[0]&0x20 == 1 {
-		header.Attr.SegmentationEnabled = true
-	}
-	var encrypt = msgAttrBytes[0] & 0x1C
-	if encrypt == 1 {
-		header.Attr.EncryptionMethod = "RSA"
-	}
-	header.Attr.BodyLength = uint16((msgAttrBytes[0]&0x03)<<8 + uint8(msgAttrBytes[1]))
-
-	if header.Attr.SegmentationEnabled {
-		var segmentBytes = h[12:]
-
-		header.SegInfo = &SegmentInfo{}
-
-		err = binary.Read(bytes.NewReader(segmentBytes[:2]), binary.BigEndian, &header.SegInfo.TotalSegments)
-		if err != nil {
-			return nil, err
-		}
-		err = binary.Read(bytes.NewReader(segmentBytes[2:]), binary.BigEndian, &header.SegInfo.SegmentNum)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return &header, nil
-}
-
 func (c *codec) trimIdentifiers(data []byte) []byte {
-	if data[0] == 0x7e {
+	if len(data) > 0 && data[0] == 0x7e {
 		data = data[1:]
 	}
-	if data[len(data)-1] == 0x7e {
+	if len(data) > 0 && data[len(data)-1] == 0x7e {
 		data = data[:len(data)-1]
 	}
 	return data
 }
 
 func (c *codec) Decode(data []byte) (*Message, error) {
+	var frame = make([]byte, len(data))
+	copy(frame, data)
+
+	var msg Message
+	err := c.DecodeInto(&msg, frame)
+	if err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+func (c *codec) DecodeInto(msg *Message, data []byte) error {
 	data = c.trimIdentifiers(data)
 
-	unescapedData := c.unescape(data)
+	unescapedData, err := unescapeInPlace(data)
+	if err != nil {
+		return err
+	}
+	if len(unescapedData) < 2 {
+		return &DecodeError{Offset: len(unescapedData), Err: ErrTruncated}
+	}
 
 	if !c.checksumVerified(unescapedData) {
-		return nil, ErrChecksumFailed
+		return ErrChecksumFailed
 	}
 
-	var msg Message
-
-	header, err := c.header.Decode(unescapedData[:MessageHeaderMaxLength])
+	headerBodyBytes := unescapedData[:len(unescapedData)-1]
+	header, err := c.decodeHeader(msg.H, headerBodyBytes)
 	if err != nil {
-		return nil, ErrDecodeHeaderFailed
+		if _, ok := err.(*DecodeError); ok || err == ErrPhoneOutOfRange {
+			return err
+		}
+		return ErrDecodeHeaderFailed
+	}
+	if header.Length() > len(headerBodyBytes) {
+		return &DecodeError{Offset: len(headerBodyBytes), Err: ErrTruncated}
 	}
 	msg.H = header
+	msg.B = nil
 
-	switch msg.H.MessageId {
-	case 0x0200:
-		c.body = &locationCodec{}
-	default:
-		return nil, ErrMessageIdNotSupported
+	bodyBytes := headerBodyBytes[header.Length():]
+	if int(header.Attr.BodyLength) != len(bodyBytes) {
+		return &DecodeError{Offset: header.Length(), Err: ErrBodyLengthMismatch}
 	}
 
-	var bodyBytes []byte
 	if header.Attr.SegmentationEnabled {
-		bodyBytes = unescapedData[MessageHeaderMaxLength : len(unescapedData)-1]
-	} else {
-		bodyBytes = unescapedData[MessageHeaderNormalLength : len(unescapedData)-1]
+		msg.B = &RawBody{Data: bodyBytes}
+		return nil
 	}
 
-	body, err := c.body.Decode(bodyBytes)
+	body, err := c.DecodeBody(header, bodyBytes)
 	if err != nil {
-		return nil, err
+		return shiftOffset(err, header.Length())
 	}
 	msg.B = body
 
-	return &msg, nil
+	return nil
+}
+
+// decodeHeader decodes into h if the header codec supports it and h is not
+// nil
+func (c *codec) decodeHeader(h *Header, data []byte) (*Header, error) {
+	hc, ok := c.header.(*headerCodec)
+	if !ok {
+		return c.header.Decode(data)
+	}
+	if h == nil {
+		h = &Header{}
+	}
+	err := hc.decodeInto(h, data)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
 }
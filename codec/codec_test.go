@@ -2,17 +2,20 @@ package codec
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/bmizerany/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
+const sampleLocationFrame = "7e02000149019161017001000000000000000040000158708c06c94a6e00000000000016101710275654470aec26cad75fdec1dc9c9fcdf89cbb9c216ade77b2b8c83a354e4ab8b5388345ac2af4b31cfa6883aafcb3a42940641e5db1b0411d0abae2aef8dfa8f07d0140adec26ca1986e6adef7be60a0601cc000024900e6100000000ffaa00000000000001cc000024900e6d00000000ffae00000000000001cc00002490128600000000ffa300000000000001cc000024900e6b00000000ffa100000000000001cc000024900ffd00000000ff9b00000000000001cc00002490114500000000ff9a000000000000fe65e602000162f2000c000151800100000000000000f3000102f400010ef5000100f900040000063520000a898602b513165013127007002e563a392e302e3030305432323b4353513a31342c302c312c312c302c322c302c302c313031383130303935312c303a7e"
+
 func TestCodec_Decode(t *testing.T) {
 	var c, _ = NewCodec(nil)
 
-	var str = "7e02000149019161017001000000000000000040000158708c06c94a6e00000000000016101710275654470aec26cad75fdec1dc9c9fcdf89cbb9c216ade77b2b8c83a354e4ab8b5388345ac2af4b31cfa6883aafcb3a42940641e5db1b0411d0abae2aef8dfa8f07d0140adec26ca1986e6adef7be60a0601cc000024900e6100000000ffaa00000000000001cc000024900e6d00000000ffae00000000000001cc00002490128600000000ffa300000000000001cc000024900e6b00000000ffa100000000000001cc000024900ffd00000000ff9b00000000000001cc00002490114500000000ff9a000000000000fe65e602000162f2000c000151800100000000000000f3000102f400010ef5000100f900040000063520000a898602b513165013127007002e563a392e302e3030305432323b4353513a31342c302c312c312c302c322c302c302c313031383130303935312c303a7e"
-
-	data, _ := hex.DecodeString(str)
+	data, _ := hex.DecodeString(sampleLocationFrame)
 	msg, err := c.Decode(data)
 	assert.Equal(t, nil, err)
 	fmt.Println(msg.Human())
@@ -23,7 +26,7 @@ func TestDecodeHeaderMsgAttr(t *testing.T) {
 
 	data, _ := hex.DecodeString(str)
 
-	header, err := (&headerCodec{}).Decode(data)
+	header, err := (&headerCodec{version: Version2013}).Decode(data)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, 512, int(header.MessageId))
 	assert.Equal(t, 48, int(header.SegInfo.TotalSegments))
@@ -34,3 +37,334 @@ func TestDecodeHeaderMsgAttr(t *testing.T) {
 	assert.Equal(t, "RSA", header.Attr.EncryptionMethod)
 	assert.Equal(t, 8, int(header.Attr.BodyLength))
 }
+
+type echoBody struct {
+	data []byte
+}
+
+func (e *echoBody) Human() string {
+	return hex.EncodeToString(e.data)
+}
+
+type echoCodec struct {
+}
+
+func (e *echoCodec) Encode(b Body) ([]byte, error) {
+	return b.(*echoBody).data, nil
+}
+
+func (e *echoCodec) Decode(data []byte) (Body, error) {
+	return &echoBody{data: data}, nil
+}
+
+func TestCodec_RegisterBodyCodec(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var msg = Message{
+		H: &Header{
+			MessageId: 0x0f01,
+			Attr:      &BodyAttr{},
+			Phone:     19161017001,
+			SerialNum: 7,
+		},
+		B: &RawBody{Data: []byte{0x01, 0x7e, 0x02}},
+	}
+
+	data, err := c.Encode(&msg)
+	assert.Equal(t, nil, err)
+
+	// unregistered ids come back raw
+	decoded, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, int(decoded.H.Attr.BodyLength))
+	assert.Equal(t, &RawBody{Data: []byte{0x01, 0x7e, 0x02}}, decoded.B)
+
+	c.RegisterBodyCodec(0x0f01, &echoCodec{})
+
+	decoded, err = c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &echoBody{data: []byte{0x01, 0x7e, 0x02}}, decoded.B)
+
+	reencoded, err := c.Encode(decoded)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, data, reencoded)
+}
+
+func TestGeneralResponseCodec(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	for _, msg := range []*Message{
+		{
+			H: &Header{MessageId: MessageIdServerResponse, Phone: 19161017001},
+			B: &ServerResponse{SerialNum: 1, ID: MessageIdLocationReport, Result: ResultAlarmConfirm},
+		},
+		{
+			H: &Header{MessageId: MessageIdTerminalResponse, Phone: 19161017001, SerialNum: 1},
+			B: &TerminalResponse{SerialNum: 2, ID: 0x8103, Result: ResultMessageError},
+		},
+		{
+			H: &Header{MessageId: MessageIdHeartbeat, Phone: 19161017001, SerialNum: 2},
+			B: &HeartbeatBody{},
+		},
+	} {
+		data, err := c.Encode(msg)
+		assert.Equal(t, nil, err)
+
+		decoded, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, msg.B, decoded.B)
+	}
+
+	assert.Equal(t, "serial num: 2\nmessage id: 33027\nresult: message error\n", (&TerminalResponse{SerialNum: 2, ID: 0x8103, Result: ResultMessageError}).Human())
+	assert.Equal(t, "not supported", resultHuman(ResultNotSupported))
+}
+
+func TestLocationCodec_RoundTrip(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+
+	encoded, err := c.Encode(msg)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sampleLocationFrame, hex.EncodeToString(encoded))
+}
+
+func TestLocationCodec_Encode(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var body = &LocationMsgBody{
+		Basic: &BasicInfo{
+			Alert:     1,
+			State:     2,
+			Latitude:  22543096,
+			Longitude: 114057865,
+			Altitude:  30,
+			Speed:     600,
+			Direction: 90,
+			Timestamp: time.Date(2016, 10, 17, 10, 27, 56, 0, DefaultTimeZone),
+		},
+		AdditionalInfos: []LocationAdditionalInfo{
+			&AdditionalInfoWifis{Wifis: []*Wifi{
+				{MacAddress: "ec:26:ca:d7:5f:de", SignalStrength: 63},
+			}},
+			&Battery{Percentage: 8, Extention: 1},
+			NewUnknownInfo(0x01, []byte{0x00, 0x00, 0x7e, 0x7d}),
+		},
+	}
+
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdLocationReport, Phone: 19161017001},
+		B: body,
+	})
+	assert.Equal(t, nil, err)
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, LocationBasicInfoLength+10+4+6, int(msg.H.Attr.BodyLength))
+
+	decoded := msg.B.(*LocationMsgBody)
+	assert.Equal(t, body.Basic, decoded.Basic)
+	assert.Equal(t, 3, len(decoded.AdditionalInfos))
+	for i, info := range body.AdditionalInfos {
+		assert.Equal(t, info.Id(), decoded.AdditionalInfos[i].Id())
+		assert.Equal(t, info.Info(), decoded.AdditionalInfos[i].Info())
+	}
+	assert.Equal(t, body.AdditionalInfos[0].(*AdditionalInfoWifis).Wifis, decoded.AdditionalInfos[0].(*AdditionalInfoWifis).Wifis)
+}
+
+func TestLocationCodec_EncodeMissingBasicInfo(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	for _, msg := range []*Message{
+		{H: &Header{MessageId: MessageIdLocationReport}, B: &LocationMsgBody{}},
+		{H: &Header{MessageId: MessageIdLocationReport}, B: (*LocationMsgBody)(nil)},
+		{H: &Header{MessageId: MessageIdLocationQueryResponse}, B: &LocationQueryResponse{}},
+		{H: &Header{MessageId: MessageIdBatchLocationReport}, B: &BatchLocationBody{Items: []*LocationMsgBody{{}}}},
+	} {
+		_, err := c.Encode(msg)
+		assert.Equal(t, ErrMissingBasicInfo, err)
+	}
+
+	// JSON input is rejected earlier
+	var decoded Message
+	assert.NotEqual(t, nil, decoded.UnmarshalJSON([]byte(`{"header":{"message_id":512},"body":{"basic":null}}`)))
+}
+
+func TestBasicInfo_Coordinates(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var basic = &BasicInfo{Timestamp: time.Date(2016, 10, 17, 10, 27, 56, 0, DefaultTimeZone)}
+	assert.Equal(t, nil, basic.SetLat(-33.868820))
+	assert.Equal(t, nil, basic.SetLon(-70.669265))
+	assert.Equal(t, true, basic.State.SouthLatitude())
+	assert.Equal(t, true, basic.State.WestLongitude())
+	assert.Equal(t, uint32(33868820), basic.Latitude)
+	assert.Equal(t, ErrInvalidCoordinate, basic.SetLat(90.5))
+	assert.Equal(t, ErrInvalidCoordinate, basic.SetLon(-180.1))
+
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdLocationReport, Phone: 19161017001},
+		B: &LocationMsgBody{Basic: basic},
+	})
+	assert.Equal(t, nil, err)
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	decoded := msg.B.(*LocationMsgBody).Basic
+	assert.Equal(t, -33.868820, decoded.Lat())
+	assert.Equal(t, -70.669265, decoded.Lon())
+
+	assert.Equal(t, nil, decoded.SetLat(22.573196))
+	assert.Equal(t, false, decoded.State.SouthLatitude())
+	assert.Equal(t, 22.573196, decoded.Lat())
+
+	// out of range coordinates fail to decode
+	basic.Longitude = 180000001
+	data, _ = c.Encode(&Message{
+		H: &Header{MessageId: MessageIdLocationReport, Phone: 19161017001},
+		B: &LocationMsgBody{Basic: basic},
+	})
+	_, err = c.Decode(data)
+	assert.Equal(t, ErrInvalidCoordinate, err)
+}
+
+// frameOf checksums and escapes unescaped header and body bytes
+func frameOf(headerBody []byte) []byte {
+	var c codec
+	data := append(append([]byte{}, headerBody...), c.checksum(headerBody))
+	return append(append([]byte{0x7e}, c.escape(data)...), 0x7e)
+}
+
+func TestCodec_DecodeMalformed(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	unescaped, err := c.(*codec).unescape(data[1 : len(data)-1])
+	assert.Equal(t, nil, err)
+	headerBody := unescaped[:len(unescaped)-1]
+
+	// every truncation fails, none panics, the closing 0x7e is optional
+	for i := 0; i < len(data)-1; i++ {
+		_, err := c.Decode(data[:i])
+		assert.NotEqual(t, nil, err)
+	}
+	for i := 0; i < len(headerBody); i++ {
+		_, err := c.Decode(frameOf(headerBody[:i]))
+		assert.NotEqual(t, nil, err)
+	}
+
+	_, err = c.Decode(frameOf(headerBody[:8]))
+	assert.Equal(t, &DecodeError{Offset: 8, Err: ErrTruncated}, err)
+
+	_, err = c.Decode(frameOf(headerBody[:len(headerBody)-1]))
+	assert.Equal(t, &DecodeError{Offset: MessageHeaderNormalLength, Err: ErrBodyLengthMismatch}, err)
+	assert.Equal(t, true, errors.Is(err, ErrBodyLengthMismatch))
+
+	// an additional info longer than the body
+	var withInfo = append(append([]byte{}, headerBody...), 0x30, 0x02, 0x01)
+	withInfo[3] += 3
+	_, err = c.Decode(frameOf(withInfo))
+	assert.Equal(t, &DecodeError{Offset: len(withInfo), Err: ErrTruncated}, err)
+
+	// bytes a body does not take, they would be lost encoding it again
+	heartbeat := []byte{0x00, 0x02, 0x00, 0x01, 0x01, 0x91, 0x61, 0x01, 0x70, 0x01, 0x00, 0x07, 0x00}
+	_, err = c.Decode(frameOf(heartbeat))
+	assert.Equal(t, &DecodeError{Offset: 12, Err: ErrTrailingData}, err)
+	response := []byte{0x80, 0x01, 0x00, 0x06, 0x01, 0x91, 0x61, 0x01, 0x70, 0x01, 0x00, 0x07, 0x00, 0x01, 0x02, 0x00, 0x00, 0xff}
+	_, err = c.Decode(frameOf(response))
+	assert.Equal(t, &DecodeError{Offset: 17, Err: ErrTrailingData}, err)
+	// reserved encryption methods
+	heartbeat = []byte{0x00, 0x02, 0x08, 0x00, 0x01, 0x91, 0x61, 0x01, 0x70, 0x01, 0x00, 0x07}
+	_, err = c.Decode(frameOf(heartbeat))
+	assert.Equal(t, ErrDecodeHeaderFailed, err)
+
+	_, err = c.Decode([]byte{0x7e, 0x01, 0x7d, 0x03, 0x02, 0x7e})
+	assert.Equal(t, &DecodeError{Offset: 1, Err: ErrInvalidEscape}, err)
+	_, err = c.Decode([]byte{0x7e, 0x01, 0x7d, 0x7e})
+	assert.Equal(t, &DecodeError{Offset: 1, Err: ErrInvalidEscape}, err)
+}
+
+func TestCodec_DecodeInto(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	expected, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+
+	var msg Message
+	var frame = append([]byte{}, data...)
+	assert.Equal(t, nil, c.DecodeInto(&msg, frame))
+	assert.Equal(t, expected, &msg)
+	// unescaped in place
+	assert.NotEqual(t, data, frame)
+
+	var h, attr = msg.H, msg.H.Attr
+	heartbeat := frameOf([]byte{0x00, 0x02, 0x00, 0x00, 0x01, 0x91, 0x61, 0x01, 0x70, 0x01, 0x00, 0x07})
+	assert.Equal(t, nil, c.DecodeInto(&msg, heartbeat))
+	assert.Equal(t, true, msg.H == h && msg.H.Attr == attr)
+	assert.Equal(t, uint16(0x0002), msg.H.MessageId)
+	assert.Equal(t, uint16(7), msg.H.SerialNum)
+}
+
+// TestCodec_Concurrent is meant for go test -race
+func TestCodec_Concurrent(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	expected, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	encoded, err := c.Encode(expected)
+	assert.Equal(t, nil, err)
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		var codec = c
+		switch i % 3 {
+		case 1:
+			codec = c.WithVendor("vendor")
+		case 2:
+			codec = c.WithTimeZone(time.UTC)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				msg, err := codec.Decode(data)
+				if err == nil {
+					_, err = codec.Encode(msg)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			c.RegisterBodyCodec(uint16(0x0f00+j), &echoCodec{})
+			c.RegisterAdditionalInfoCodec(0xe1, decodeTestFuelLevel)
+			c.RegisterVendorAdditionalInfoCodec("vendor", 0xe1, decodeTestFuelLevel)
+			c.WithVendor("vendor")
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, msg)
+	reencoded, _ := c.Encode(msg)
+	assert.Equal(t, encoded, reencoded)
+}
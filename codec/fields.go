@@ -0,0 +1,119 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+var (
+	ErrFieldTooLong = errors.New("field exceeds its fixed length")
+)
+
+// bodyReader reads big endian fields in order. The first read past the end
+// sets err to a *DecodeError of ErrTruncated at the offset of that read, and
+// every read after that returns zero values.
+type bodyReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func newBodyReader(data []byte) *bodyReader {
+	return &bodyReader{data: data}
+}
+
+func (r *bodyReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data)-r.off < n {
+		r.err = &DecodeError{Offset: r.off, Err: ErrTruncated}
+		return nil
+	}
+	bs := r.data[r.off : r.off+n]
+	r.off += n
+	return bs
+}
+
+// BYTE
+func (r *bodyReader) byte() uint8 {
+	bs := r.next(1)
+	if bs == nil {
+		return 0
+	}
+	return bs[0]
+}
+
+// WORD
+func (r *bodyReader) word() uint16 {
+	bs := r.next(2)
+	if bs == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(bs)
+}
+
+// DWORD
+func (r *bodyReader) dword() uint32 {
+	bs := r.next(4)
+	if bs == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(bs)
+}
+
+// BYTE[n]
+func (r *bodyReader) bytes(n int) []byte {
+	return r.next(n)
+}
+
+// done returns the error of the reads, or a *DecodeError of ErrTrailingData
+// if bytes are left, so that decoded bodies encode to the very same bytes
+func (r *bodyReader) done() error {
+	if r.err == nil && r.off < len(r.data) {
+		return &DecodeError{Offset: r.off, Err: ErrTrailingData}
+	}
+	return r.err
+}
+
+func (r *bodyReader) rest() []byte {
+	return r.next(len(r.data) - r.off)
+}
+
+func (r *bodyReader) len() int {
+	return len(r.data) - r.off
+}
+
+// WORD
+func appendWord(dst []byte, v uint16) []byte {
+	return append(dst, uint8(v>>8), uint8(v))
+}
+
+// DWORD
+func appendDword(dst []byte, v uint32) []byte {
+	return append(dst, uint8(v>>24), uint8(v>>16), uint8(v>>8), uint8(v))
+}
+
+// BYTE[n] string, GBK encoded and right padded with 0x00
+func appendFixedString(dst []byte, s string, n int) ([]byte, error) {
+	bs, err := utils.EncodeGBK(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) > n {
+		return nil, ErrFieldTooLong
+	}
+	dst = append(dst, bs...)
+	for i := len(bs); i < n; i++ {
+		dst = append(dst, 0)
+	}
+	return dst, nil
+}
+
+// BYTE[n] string, the 0x00 padding is dropped
+func decodeFixedString(data []byte) (string, error) {
+	return utils.DecodeGBK(bytes.TrimRight(data, "\x00"))
+}
@@ -0,0 +1,361 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AlarmFlags is the alarm bitfield of BasicInfo
+type AlarmFlags uint32
+
+const (
+	AlarmEmergency AlarmFlags = 1 << iota
+	AlarmOverspeed
+	AlarmFatigueDriving
+	AlarmDangerWarning
+	AlarmGNSSModuleFault
+	AlarmGNSSAntennaDisconnected
+	AlarmGNSSAntennaShortCircuit
+	AlarmPowerUndervoltage
+	AlarmPowerOff
+	AlarmLCDFault
+	AlarmTTSFault
+	AlarmCameraFault
+	AlarmICCardFault
+	AlarmOverspeedWarning
+	AlarmFatigueDrivingWarning
+	_
+	_
+	_
+	AlarmDrivingTimeout
+	AlarmParkingTimeout
+	AlarmArea
+	AlarmRoute
+	AlarmRouteDrivingTime
+	AlarmRouteDeviation
+	AlarmVSSFault
+	AlarmFuelAbnormal
+	AlarmStolen
+	AlarmIllegalIgnition
+	AlarmIllegalDisplacement
+	AlarmCollision
+	AlarmRollover
+	AlarmIllegalDoorOpen
+)
+
+// indexed by bit, reserved bits have no name
+var alarmNames = [32]string{
+	"emergency",
+	"overspeed",
+	"fatigue_driving",
+	"danger_warning",
+	"gnss_module_fault",
+	"gnss_antenna_disconnected",
+	"gnss_antenna_short_circuit",
+	"power_undervoltage",
+	"power_off",
+	"lcd_fault",
+	"tts_fault",
+	"camera_fault",
+	"ic_card_fault",
+	"overspeed_warning",
+	"fatigue_driving_warning",
+	"",
+	"",
+	"",
+	"driving_timeout",
+	"parking_timeout",
+	"area",
+	"route",
+	"route_driving_time",
+	"route_deviation",
+	"vss_fault",
+	"fuel_abnormal",
+	"stolen",
+	"illegal_ignition",
+	"illegal_displacement",
+	"collision",
+	"rollover",
+	"illegal_door_open",
+}
+
+// ParseAlarmFlags sets the flags named as in Set()
+func ParseAlarmFlags(names ...string) (AlarmFlags, error) {
+	var a AlarmFlags
+	for _, name := range names {
+		bit, ok := flagBit(&alarmNames, name)
+		if !ok {
+			return 0, fmt.Errorf("unknown alarm flag %q", name)
+		}
+		a |= 1 << bit
+	}
+	return a, nil
+}
+
+func (a AlarmFlags) Has(f AlarmFlags) bool {
+	return a&f == f
+}
+
+func (a AlarmFlags) Emergency() bool               { return a.Has(AlarmEmergency) }
+func (a AlarmFlags) Overspeed() bool               { return a.Has(AlarmOverspeed) }
+func (a AlarmFlags) FatigueDriving() bool          { return a.Has(AlarmFatigueDriving) }
+func (a AlarmFlags) DangerWarning() bool           { return a.Has(AlarmDangerWarning) }
+func (a AlarmFlags) GNSSModuleFault() bool         { return a.Has(AlarmGNSSModuleFault) }
+func (a AlarmFlags) GNSSAntennaDisconnected() bool { return a.Has(AlarmGNSSAntennaDisconnected) }
+func (a AlarmFlags) GNSSAntennaShortCircuit() bool { return a.Has(AlarmGNSSAntennaShortCircuit) }
+func (a AlarmFlags) PowerUndervoltage() bool       { return a.Has(AlarmPowerUndervoltage) }
+func (a AlarmFlags) PowerOff() bool                { return a.Has(AlarmPowerOff) }
+func (a AlarmFlags) LCDFault() bool                { return a.Has(AlarmLCDFault) }
+func (a AlarmFlags) TTSFault() bool                { return a.Has(AlarmTTSFault) }
+func (a AlarmFlags) CameraFault() bool             { return a.Has(AlarmCameraFault) }
+func (a AlarmFlags) ICCardFault() bool             { return a.Has(AlarmICCardFault) }
+func (a AlarmFlags) OverspeedWarning() bool        { return a.Has(AlarmOverspeedWarning) }
+func (a AlarmFlags) FatigueDrivingWarning() bool   { return a.Has(AlarmFatigueDrivingWarning) }
+func (a AlarmFlags) DrivingTimeout() bool          { return a.Has(AlarmDrivingTimeout) }
+func (a AlarmFlags) ParkingTimeout() bool          { return a.Has(AlarmParkingTimeout) }
+func (a AlarmFlags) Area() bool                    { return a.Has(AlarmArea) }
+func (a AlarmFlags) Route() bool                   { return a.Has(AlarmRoute) }
+func (a AlarmFlags) RouteDrivingTime() bool        { return a.Has(AlarmRouteDrivingTime) }
+func (a AlarmFlags) RouteDeviation() bool          { return a.Has(AlarmRouteDeviation) }
+func (a AlarmFlags) VSSFault() bool                { return a.Has(AlarmVSSFault) }
+func (a AlarmFlags) FuelAbnormal() bool            { return a.Has(AlarmFuelAbnormal) }
+func (a AlarmFlags) Stolen() bool                  { return a.Has(AlarmStolen) }
+func (a AlarmFlags) IllegalIgnition() bool         { return a.Has(AlarmIllegalIgnition) }
+func (a AlarmFlags) IllegalDisplacement() bool     { return a.Has(AlarmIllegalDisplacement) }
+func (a AlarmFlags) Collision() bool               { return a.Has(AlarmCollision) }
+func (a AlarmFlags) Rollover() bool                { return a.Has(AlarmRollover) }
+func (a AlarmFlags) IllegalDoorOpen() bool         { return a.Has(AlarmIllegalDoorOpen) }
+
+// Set returns the names of the active flags, reserved bits as bit_<n>
+func (a AlarmFlags) Set() []string {
+	return flagNames(&alarmNames, uint32(a))
+}
+
+func (a AlarmFlags) String() string {
+	return flagsString(a.Set())
+}
+
+// MarshalJSON encodes the names of Set()
+func (a AlarmFlags) MarshalJSON() ([]byte, error) {
+	return marshalFlags(a.Set())
+}
+
+// UnmarshalJSON accepts a list of names or the number
+func (a *AlarmFlags) UnmarshalJSON(data []byte) error {
+	names, n, err := unmarshalFlags(data)
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		*a = AlarmFlags(n)
+		return nil
+	}
+	*a, err = ParseAlarmFlags(names...)
+	return err
+}
+
+// StatusFlags is the status bitfield of BasicInfo
+type StatusFlags uint32
+
+const (
+	StatusACCOn StatusFlags = 1 << iota
+	StatusPositioned
+	StatusSouthLatitude
+	StatusWestLongitude
+	StatusOutOfService
+	StatusCoordinatesEncrypted
+	_
+	_
+	// bits 8 and 9 are the load state, see LoadState()
+	StatusLoadHalf
+	statusLoadReserved
+	StatusOilCircuitCut
+	StatusCircuitCut
+	StatusDoorLocked
+	StatusFrontDoorOpen
+	StatusMiddleDoorOpen
+	StatusBackDoorOpen
+	StatusDriverDoorOpen
+	StatusCustomDoorOpen
+	StatusGPS
+	StatusBeidou
+	StatusGLONASS
+	StatusGalileo
+
+	StatusLoadFull = StatusLoadHalf | statusLoadReserved
+	statusLoadMask = StatusLoadFull
+)
+
+// load states
+const (
+	LoadEmpty    uint8 = 0
+	LoadHalf     uint8 = 1
+	LoadReserved uint8 = 2
+	LoadFull     uint8 = 3
+)
+
+// indexed by bit, the load bits are named by LoadState()
+var statusNames = [32]string{
+	"acc_on",
+	"positioned",
+	"south_latitude",
+	"west_longitude",
+	"out_of_service",
+	"coordinates_encrypted",
+	"",
+	"",
+	"",
+	"",
+	"oil_circuit_cut",
+	"circuit_cut",
+	"door_locked",
+	"front_door_open",
+	"middle_door_open",
+	"back_door_open",
+	"driver_door_open",
+	"custom_door_open",
+	"gps",
+	"beidou",
+	"glonass",
+	"galileo",
+}
+
+var loadNames = map[uint8]string{
+	LoadHalf:     "load_half",
+	LoadReserved: "load_reserved",
+	LoadFull:     "load_full",
+}
+
+// ParseStatusFlags sets the flags named as in Set()
+func ParseStatusFlags(names ...string) (StatusFlags, error) {
+	var s StatusFlags
+outer:
+	for _, name := range names {
+		for load, loadName := range loadNames {
+			if name == loadName {
+				s = s&^statusLoadMask | StatusFlags(load)<<8
+				continue outer
+			}
+		}
+		bit, ok := flagBit(&statusNames, name)
+		if !ok {
+			return 0, fmt.Errorf("unknown status flag %q", name)
+		}
+		s |= 1 << bit
+	}
+	return s, nil
+}
+
+func (s StatusFlags) Has(f StatusFlags) bool {
+	return s&f == f
+}
+
+func (s StatusFlags) ACCOn() bool                { return s.Has(StatusACCOn) }
+func (s StatusFlags) Positioned() bool           { return s.Has(StatusPositioned) }
+func (s StatusFlags) SouthLatitude() bool        { return s.Has(StatusSouthLatitude) }
+func (s StatusFlags) WestLongitude() bool        { return s.Has(StatusWestLongitude) }
+func (s StatusFlags) Operating() bool            { return !s.Has(StatusOutOfService) }
+func (s StatusFlags) CoordinatesEncrypted() bool { return s.Has(StatusCoordinatesEncrypted) }
+func (s StatusFlags) OilCircuitCut() bool        { return s.Has(StatusOilCircuitCut) }
+func (s StatusFlags) CircuitCut() bool           { return s.Has(StatusCircuitCut) }
+func (s StatusFlags) DoorLocked() bool           { return s.Has(StatusDoorLocked) }
+func (s StatusFlags) FrontDoorOpen() bool        { return s.Has(StatusFrontDoorOpen) }
+func (s StatusFlags) MiddleDoorOpen() bool       { return s.Has(StatusMiddleDoorOpen) }
+func (s StatusFlags) BackDoorOpen() bool         { return s.Has(StatusBackDoorOpen) }
+func (s StatusFlags) DriverDoorOpen() bool       { return s.Has(StatusDriverDoorOpen) }
+func (s StatusFlags) CustomDoorOpen() bool       { return s.Has(StatusCustomDoorOpen) }
+func (s StatusFlags) GPS() bool                  { return s.Has(StatusGPS) }
+func (s StatusFlags) Beidou() bool               { return s.Has(StatusBeidou) }
+func (s StatusFlags) GLONASS() bool              { return s.Has(StatusGLONASS) }
+func (s StatusFlags) Galileo() bool              { return s.Has(StatusGalileo) }
+
+// LoadState returns one of LoadEmpty, LoadHalf, LoadReserved and LoadFull
+func (s StatusFlags) LoadState() uint8 {
+	return uint8((s & statusLoadMask) >> 8)
+}
+
+// Set returns the names of the active flags and of a non-empty load state,
+// reserved bits as bit_<n>
+func (s StatusFlags) Set() []string {
+	names := flagNames(&statusNames, uint32(s&^statusLoadMask))
+	if load := s.LoadState(); load != LoadEmpty {
+		names = append(names, loadNames[load])
+	}
+	return names
+}
+
+func (s StatusFlags) String() string {
+	return flagsString(s.Set())
+}
+
+// MarshalJSON encodes the names of Set()
+func (s StatusFlags) MarshalJSON() ([]byte, error) {
+	return marshalFlags(s.Set())
+}
+
+// UnmarshalJSON accepts a list of names or the number
+func (s *StatusFlags) UnmarshalJSON(data []byte) error {
+	names, n, err := unmarshalFlags(data)
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		*s = StatusFlags(n)
+		return nil
+	}
+	*s, err = ParseStatusFlags(names...)
+	return err
+}
+
+func flagBit(names *[32]string, name string) (uint, bool) {
+	for bit := range names {
+		if names[bit] == name && name != "" {
+			return uint(bit), true
+		}
+	}
+	var bit uint
+	if _, err := fmt.Sscanf(name, "bit_%d", &bit); err == nil && bit < 32 {
+		return bit, true
+	}
+	return 0, false
+}
+
+func flagNames(names *[32]string, v uint32) []string {
+	var res = []string{}
+	for bit := uint(0); bit < 32; bit++ {
+		if v&(1<<bit) == 0 {
+			continue
+		}
+		if names[bit] == "" {
+			res = append(res, fmt.Sprintf("bit_%d", bit))
+			continue
+		}
+		res = append(res, names[bit])
+	}
+	return res
+}
+
+func flagsString(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+func marshalFlags(names []string) ([]byte, error) {
+	return jsonAPI.Marshal(names)
+}
+
+// unmarshalFlags returns either names or, if data is a number, n
+func unmarshalFlags(data []byte) ([]string, uint32, error) {
+	var n uint32
+	if err := jsonAPI.Unmarshal(data, &n); err == nil {
+		return nil, n, nil
+	}
+
+	var names = []string{}
+	if err := jsonAPI.Unmarshal(data, &names); err != nil {
+		return nil, 0, err
+	}
+	return names, 0, nil
+}
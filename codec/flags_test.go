@@ -0,0 +1,66 @@
+package codec
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestAlarmFlags(t *testing.T) {
+	a, err := ParseAlarmFlags("emergency", "gnss_antenna_disconnected", "illegal_door_open", "bit_16")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, AlarmEmergency|AlarmGNSSAntennaDisconnected|AlarmIllegalDoorOpen|1<<16, a)
+	assert.Equal(t, true, a.Emergency())
+	assert.Equal(t, true, a.GNSSAntennaDisconnected())
+	assert.Equal(t, false, a.Overspeed())
+	assert.Equal(t, "emergency,gnss_antenna_disconnected,bit_16,illegal_door_open", a.String())
+	assert.Equal(t, "none", AlarmFlags(0).String())
+
+	data, err := json.Marshal(a)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `["emergency","gnss_antenna_disconnected","bit_16","illegal_door_open"]`, string(data))
+
+	var decoded AlarmFlags
+	assert.Equal(t, nil, json.Unmarshal(data, &decoded))
+	assert.Equal(t, a, decoded)
+	assert.Equal(t, nil, json.Unmarshal([]byte("3"), &decoded))
+	assert.Equal(t, AlarmEmergency|AlarmOverspeed, decoded)
+
+	_, err = ParseAlarmFlags("on_fire")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestStatusFlags(t *testing.T) {
+	s, err := ParseStatusFlags("acc_on", "positioned", "west_longitude", "load_full", "gps", "beidou")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, s.ACCOn())
+	assert.Equal(t, true, s.Positioned())
+	assert.Equal(t, false, s.SouthLatitude())
+	assert.Equal(t, true, s.WestLongitude())
+	assert.Equal(t, true, s.Operating())
+	assert.Equal(t, LoadFull, s.LoadState())
+	assert.Equal(t, []string{"acc_on", "positioned", "west_longitude", "gps", "beidou", "load_full"}, s.Set())
+
+	data, _ := json.Marshal(s)
+	var decoded StatusFlags
+	assert.Equal(t, nil, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s, decoded)
+
+	s, _ = ParseStatusFlags("load_full", "load_half")
+	assert.Equal(t, LoadHalf, s.LoadState())
+}
+
+func TestBasicInfoFlags(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+
+	basic := msg.B.(*LocationMsgBody).Basic
+	assert.Equal(t, AlarmFlags(0), basic.Alert)
+	assert.Equal(t, []string{}, basic.Alert.Set())
+	assert.Equal(t, []string{"middle_door_open"}, basic.State.Set())
+}
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// enough for the largest body (1023 bytes) with every byte escaped
+	DefaultMaxFrameSize = 4096
+
+	frameIdentifier = 0x7e
+)
+
+var (
+	ErrFrameTooLarge = errors.New("frame exceeds max frame size")
+)
+
+// FrameError reports a single frame that could not be read or decoded,
+// the FrameReader stays usable and continues with the next frame.
+type FrameError struct {
+	Frame []byte
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("bad frame %s: %s", hex.EncodeToString(e.Frame), e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// FrameReader splits a byte stream into 0x7e delimited frames, bytes outside
+// of frames are skipped.
+type FrameReader struct {
+	r            *bufio.Reader
+	codec        Codec
+	maxFrameSize int
+}
+
+func NewFrameReader(r io.Reader, c Codec) *FrameReader {
+	return &FrameReader{
+		r:            bufio.NewReader(r),
+		codec:        c,
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// SetMaxFrameSize limits the frame length, identifiers included
+func (f *FrameReader) SetMaxFrameSize(n int) {
+	f.maxFrameSize = n
+}
+
+// ReadFrame returns the next frame with both identifiers, an oversized frame
+// is dropped and reported as a *FrameError wrapping ErrFrameTooLarge.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	// catch start, anything before it is junk
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == frameIdentifier {
+			break
+		}
+	}
+
+	var frame = []byte{frameIdentifier}
+	var tooLarge = false
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if b != frameIdentifier {
+			if tooLarge {
+				continue
+			}
+			frame = append(frame, b)
+			// leave room for the end identifier
+			if len(frame) >= f.maxFrameSize {
+				tooLarge = true
+			}
+			continue
+		}
+
+		// catch end
+		if tooLarge {
+			return nil, &FrameError{Frame: frame, Err: ErrFrameTooLarge}
+		}
+		// back to back identifiers, the second one starts the frame
+		if len(frame) == 1 {
+			continue
+		}
+		return append(frame, frameIdentifier), nil
+	}
+}
+
+// ReadMessage reads and decodes the next frame. Errors of a single frame are
+// returned as *FrameError, any other error comes from the underlying reader.
+func (f *FrameReader) ReadMessage() (*Message, error) {
+	frame, err := f.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := f.codec.Decode(frame)
+	if err != nil {
+		return nil, &FrameError{Frame: frame, Err: err}
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestFrameReader_ReadMessage(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	good, err := c.Encode(&Message{
+		H: &Header{MessageId: 0x0f01, Phone: 19161017001},
+		B: &RawBody{Data: []byte{0x01, 0x02}},
+	})
+	assert.Equal(t, nil, err)
+
+	badChecksum := append([]byte{}, good...)
+	badChecksum[len(badChecksum)-2] ^= 0xff
+
+	var stream bytes.Buffer
+	// junk before the first frame
+	stream.Write([]byte{0x00, 0x01})
+	stream.Write(good)
+	// back to back identifiers
+	stream.Write([]byte{0x7e})
+	stream.Write(good[1:])
+	// junk between frames
+	stream.Write([]byte{0x33})
+	stream.Write(badChecksum)
+	// oversized frame
+	stream.Write([]byte{0x7e})
+	stream.Write(bytes.Repeat([]byte{0x01}, 64))
+	stream.Write([]byte{0x7e})
+	stream.Write(good)
+	// truncated at EOF
+	stream.Write(good[:5])
+
+	fr := NewFrameReader(&stream, c)
+	fr.SetMaxFrameSize(32)
+
+	for i := 0; i < 2; i++ {
+		msg, err := fr.ReadMessage()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, uint16(0x0f01), msg.H.MessageId)
+	}
+
+	_, err = fr.ReadMessage()
+	frameErr, ok := err.(*FrameError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, ErrChecksumFailed, frameErr.Err)
+	assert.Equal(t, hex.EncodeToString(badChecksum), hex.EncodeToString(frameErr.Frame))
+
+	_, err = fr.ReadMessage()
+	frameErr, ok = err.(*FrameError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, ErrFrameTooLarge, frameErr.Err)
+
+	msg, err := fr.ReadMessage()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &RawBody{Data: []byte{0x01, 0x02}}, msg.B)
+
+	_, err = fr.ReadMessage()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = fr.ReadMessage()
+	assert.Equal(t, io.EOF, err)
+}
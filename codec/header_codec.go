@@ -1,30 +1,44 @@
 package codec
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
-	"strconv"
-	"strings"
 
 	"github.com/sceneryback/jtt808/utils"
 )
 
+const (
+	phoneLength2013 = 6
+	phoneLength2019 = 10
+
+	attrVersionFlag = 0x4000
+)
+
 type headerCodec struct {
+	// VersionAuto detects the version by the version flag in the attributes
+	version string
 }
 
 func (c *headerCodec) Encode(h *Header) ([]byte, error) {
-	var res []byte
+	return c.appendHeader(make([]byte, 0, MessageHeaderMaxLength2019), h)
+}
 
-	var msgIdBuf bytes.Buffer
-	err := binary.Write(&msgIdBuf, binary.BigEndian, h.MessageId)
-	if err != nil {
-		return nil, err
+// appendHeader appends the encoded header to dst
+func (c *headerCodec) appendHeader(dst []byte, h *Header) ([]byte, error) {
+	var version = h.Version
+	if version == VersionAuto {
+		version = c.version
+	}
+	if version == VersionAuto {
+		version = Version2013
 	}
-	res = append(res, msgIdBuf.Bytes()...)
 
 	var attr uint16
-	attr |= uint16(h.Attr.Preserved) << 14
+	if version == Version2019 {
+		attr |= uint16(h.Attr.Preserved&0x01) << 15
+		attr |= attrVersionFlag
+	} else {
+		attr |= uint16(h.Attr.Preserved) << 14
+	}
 	if h.Attr.SegmentationEnabled {
 		attr |= 0x2000
 	}
@@ -33,107 +47,143 @@ func (c *headerCodec) Encode(h *Header) ([]byte, error) {
 	}
 	attr |= h.Attr.BodyLength
 
-	var attrBuf bytes.Buffer
-	err = binary.Write(&attrBuf, binary.BigEndian, attr)
-	if err != nil {
-		return nil, err
-	}
-	res = append(res, attrBuf.Bytes()...)
+	dst = appendWord(dst, h.MessageId)
+	dst = appendWord(dst, attr)
 
-	phoneStr := fmt.Sprintf("%d", h.Phone)
-	var fillPhoneStr = phoneStr
-	if len(phoneStr) < 12 {
-		for i := 0; i < 12-len(phoneStr); i++ {
-			fillPhoneStr = "0" + fillPhoneStr
-		}
+	var phoneLength = phoneLength2013
+	if version == Version2019 {
+		dst = append(dst, h.ProtocolVersion)
+		phoneLength = phoneLength2019
 	}
-	res = append(res, utils.EncodeBCD(fillPhoneStr)...)
 
-	var serialNumBuf bytes.Buffer
-	err = binary.Write(&serialNumBuf, binary.BigEndian, h.SerialNum)
-	if err != nil {
-		return nil, err
+	dst, ok := utils.AppendBCDUint(dst, h.Phone, phoneLength)
+	if !ok {
+		return nil, ErrFieldTooLong
 	}
-	res = append(res, serialNumBuf.Bytes()...)
-
-	if h.Attr.SegmentationEnabled {
-		var totalSegsBuf, segSeqBuf bytes.Buffer
 
-		err = binary.Write(&totalSegsBuf, binary.BigEndian, &h.SegInfo.TotalSegments)
-		if err != nil {
-			return nil, err
-		}
+	dst = appendWord(dst, h.SerialNum)
 
-		err = binary.Write(&segSeqBuf, binary.BigEndian, &h.SegInfo.SegmentNum)
-		if err != nil {
-			return nil, err
+	if h.Attr.SegmentationEnabled {
+		var seg SegmentInfo
+		if h.SegInfo != nil {
+			seg = *h.SegInfo
 		}
-
-		res = append(res, totalSegsBuf.Bytes()...)
-		res = append(res, segSeqBuf.Bytes()...)
+		dst = appendWord(dst, seg.TotalSegments)
+		dst = appendWord(dst, seg.SegmentNum)
 	}
 
-	return res, nil
+	return dst, nil
 }
 
 func (c *headerCodec) Decode(h []byte) (*Header, error) {
 	var header Header
 
-	var msgIdBytes = h[:2]
-	var msgAttrBytes = h[2:4]
-	var phoneBytes = h[4:10]
-	var serialNumBytes = h[10:12]
-
-	err := binary.Read(bytes.NewReader(msgIdBytes), binary.BigEndian, &header.MessageId)
+	err := c.decodeInto(&header, h)
 	if err != nil {
 		return nil, err
 	}
 
-	phoneStr := strings.TrimPrefix(utils.DecodeBCD(phoneBytes), "0")
-	phone, err := strconv.ParseUint(phoneStr, 10, 64)
-	if err != nil {
-		return nil, err
+	return &header, nil
+}
+
+// decodeInto overwrites header, reusing its Attr and SegInfo, and allocates
+// nothing else
+func (c *headerCodec) decodeInto(header *Header, h []byte) error {
+	if len(h) < 4 {
+		return &DecodeError{Offset: len(h), Err: ErrTruncated}
 	}
-	header.Phone = phone
 
-	err = binary.Read(bytes.NewReader(serialNumBytes), binary.BigEndian, &header.SerialNum)
-	if err != nil {
-		return nil, err
+	var attr = binary.BigEndian.Uint16(h[2:4])
+
+	var version = c.version
+	if version == VersionAuto {
+		version = Version2013
+		if attr&attrVersionFlag != 0 {
+			version = Version2019
+		}
 	}
 
-	header.Attr = &BodyAttr{}
-	header.Attr.Preserved = uint8(msgAttrBytes[0] >> 6)
-	if (msgAttrBytes[0]&0x20)>>5 == 1 {
-		header.Attr.SegmentationEnabled = true
+	// encryption 0 is none and 1 RSA, the others are reserved
+	if (attr>>10)&0x07 > 1 {
+		return ErrDecodeHeaderFailed
 	}
-	var encrypt = (msgAttrBytes[0] & 0x1C) >> 2
-	if encrypt == 1 {
-		header.Attr.EncryptionMethod = "RSA"
+
+	// 2019 headers carry a protocol version and a longer phone
+	var length = MessageHeaderNormalLength
+	if version == Version2019 {
+		length = MessageHeaderNormalLength2019
 	}
-	// 0x0149
-	//0000 0001 0100 1001
-	//0000 0011
-	var attr uint16
-	err = binary.Read(bytes.NewBuffer(msgAttrBytes), binary.BigEndian, &attr)
-	if err != nil {
-		return nil, err
+	var segmented = attr&0x2000 != 0
+	if segmented {
+		length += 4
+	}
+	if len(h) < length {
+		return &DecodeError{Offset: len(h), Err: ErrTruncated}
 	}
-	header.Attr.BodyLength = attr & 0x03ff
-
-	if header.Attr.SegmentationEnabled {
-		var segmentBytes = h[12:]
 
-		header.SegInfo = &SegmentInfo{}
+	var phoneLength = phoneLength2013
+	var rest = h[4:]
+	var protocolVersion uint8
+	if version == Version2019 {
+		protocolVersion = rest[0]
+		rest = rest[1:]
+		phoneLength = phoneLength2019
+	}
 
-		err = binary.Read(bytes.NewReader(segmentBytes[:2]), binary.BigEndian, &header.SegInfo.TotalSegments)
-		if err != nil {
-			return nil, err
+	phone, ok := utils.ParseBCDUint(rest[:phoneLength])
+	if !ok {
+		if validBCD(rest[:phoneLength]) {
+			return ErrPhoneOutOfRange
 		}
-		err = binary.Read(bytes.NewReader(segmentBytes[2:]), binary.BigEndian, &header.SegInfo.SegmentNum)
-		if err != nil {
-			return nil, err
+		return ErrDecodeHeaderFailed
+	}
+
+	var bodyAttr = header.Attr
+	if bodyAttr == nil {
+		bodyAttr = &BodyAttr{}
+	}
+	var segInfo = header.SegInfo
+
+	*header = Header{
+		MessageId:       binary.BigEndian.Uint16(h[:2]),
+		Attr:            bodyAttr,
+		Version:         version,
+		ProtocolVersion: protocolVersion,
+		Phone:           phone,
+		SerialNum:       binary.BigEndian.Uint16(rest[phoneLength:]),
+	}
+
+	*bodyAttr = BodyAttr{
+		SegmentationEnabled: segmented,
+		BodyLength:          attr & 0x03ff,
+	}
+	if version == Version2019 {
+		bodyAttr.Preserved = uint8(attr >> 15)
+	} else {
+		bodyAttr.Preserved = uint8(attr >> 14)
+	}
+	if (attr>>10)&0x07 == 1 {
+		bodyAttr.EncryptionMethod = "RSA"
+	}
+
+	if segmented {
+		if segInfo == nil {
+			segInfo = &SegmentInfo{}
 		}
+		segmentBytes := rest[phoneLength+2:]
+		segInfo.TotalSegments = binary.BigEndian.Uint16(segmentBytes)
+		segInfo.SegmentNum = binary.BigEndian.Uint16(segmentBytes[2:])
+		header.SegInfo = segInfo
 	}
 
-	return &header, nil
+	return nil
+}
+
+func validBCD(data []byte) bool {
+	for _, b := range data {
+		if b>>4 > 9 || b&0x0f > 9 {
+			return false
+		}
+	}
+	return true
 }
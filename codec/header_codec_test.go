@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestDecodeHeader2019(t *testing.T) {
+	var str = "020060480100000000019161017001000700200010"
+
+	data, _ := hex.DecodeString(str)
+
+	header, err := (&headerCodec{}).Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Version2019, header.Version)
+	assert.Equal(t, 1, int(header.ProtocolVersion))
+	assert.Equal(t, 19161017001, int(header.Phone))
+	assert.Equal(t, 7, int(header.SerialNum))
+	assert.Equal(t, true, header.Attr.SegmentationEnabled)
+	assert.Equal(t, 0, int(header.Attr.Preserved))
+	assert.Equal(t, 72, int(header.Attr.BodyLength))
+	assert.Equal(t, 32, int(header.SegInfo.TotalSegments))
+	assert.Equal(t, 16, int(header.SegInfo.SegmentNum))
+	assert.Equal(t, MessageHeaderMaxLength2019, header.Length())
+
+	encoded, err := (&headerCodec{}).Encode(header)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, str, hex.EncodeToString(encoded))
+
+	// the version flag is a preserved bit to 2013 codecs
+	header, err = (&headerCodec{version: Version2013}).Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Version2013, header.Version)
+	assert.Equal(t, 1, int(header.Attr.Preserved))
+}
+
+func TestDecodeHeader2019_PhoneRange(t *testing.T) {
+	var hc = &headerCodec{}
+
+	// the max uint64 is the largest 20 digit phone
+	var h = &Header{MessageId: MessageIdHeartbeat, Version: Version2019, Phone: 18446744073709551615, Attr: &BodyAttr{}}
+	data, err := hc.Encode(h)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "18446744073709551615", hex.EncodeToString(data[5:15]))
+	decoded, err := hc.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, h.Phone, decoded.Phone)
+
+	copy(data[5:15], []byte{0x18, 0x44, 0x67, 0x44, 0x07, 0x37, 0x09, 0x55, 0x16, 0x16})
+	_, err = hc.Decode(data)
+	assert.Equal(t, ErrPhoneOutOfRange, err)
+	copy(data[5:15], []byte{0x99, 0x99, 0x99, 0x99, 0x99, 0x99, 0x99, 0x99, 0x99, 0x99})
+	_, err = hc.Decode(data)
+	assert.Equal(t, ErrPhoneOutOfRange, err)
+	// and from whole frames
+	var c, _ = NewCodec(nil)
+	_, err = c.Decode(frameOf(data))
+	assert.Equal(t, ErrPhoneOutOfRange, err)
+
+	data[14] = 0x1a
+	_, err = hc.Decode(data)
+	assert.Equal(t, ErrDecodeHeaderFailed, err)
+}
+
+func TestCodec_Version(t *testing.T) {
+	_, err := NewCodec(&CodecConfig{Version: "2011"})
+	assert.Equal(t, ErrVersionNotSupported, err)
+
+	c2013, _ := NewCodec(&CodecConfig{Version: Version2013})
+	c2019, _ := NewCodec(&CodecConfig{Version: Version2019})
+	auto, _ := NewCodec(nil)
+
+	var register = &RegisterMsgBody{
+		ProvinceId:     44,
+		CityId:         300,
+		ManufacturerId: "MANUFACTURE",
+		TerminalModel:  "terminal model longer than 20",
+		TerminalId:     "terminal id longer than seven",
+		PlateColor:     1,
+		PlateNumber:    "粤B12345",
+	}
+	var auth = &AuthMsgBody{
+		AuthCode:        "a1b2c3",
+		IMEI:            "861234567890123",
+		SoftwareVersion: "V1.0.2",
+	}
+
+	for _, b := range []struct {
+		id   uint16
+		body Body
+	}{
+		{MessageIdTerminalRegister, register},
+		{MessageIdTerminalAuth, auth},
+	} {
+		// 2019 bodies by codec config
+		data, err := c2019.Encode(&Message{
+			H: &Header{MessageId: b.id, Phone: 12345678901234567890, ProtocolVersion: 1},
+			B: b.body,
+		})
+		assert.Equal(t, nil, err)
+
+		msg, err := auto.Decode(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Version2019, msg.H.Version)
+		assert.Equal(t, uint64(12345678901234567890), msg.H.Phone)
+		assert.Equal(t, b.body, msg.B)
+
+		// and by header
+		reencoded, err := auto.Encode(msg)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, data, reencoded)
+	}
+
+	_, err = c2013.Encode(&Message{
+		H: &Header{MessageId: MessageIdTerminalRegister, Phone: 19161017001},
+		B: register,
+	})
+	assert.Equal(t, ErrFieldTooLong, err)
+
+	data, err := c2013.Encode(&Message{
+		H: &Header{MessageId: MessageIdTerminalAuth, Phone: 19161017001},
+		B: auth,
+	})
+	assert.Equal(t, nil, err)
+	msg, err := auto.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Version2013, msg.H.Version)
+	assert.Equal(t, &AuthMsgBody{AuthCode: "a1b2c3"}, msg.B)
+}
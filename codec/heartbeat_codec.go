@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"errors"
+)
+
+var (
+	ErrBodyNotHeartbeat = errors.New("body is not heartbeat")
+)
+
+// HeartbeatBody is the empty terminal heartbeat (0x0002) body
+type HeartbeatBody struct {
+}
+
+func (h *HeartbeatBody) Human() string {
+	return "heartbeat\n"
+}
+
+type heartbeatCodec struct {
+}
+
+// 0x0002
+func (c *heartbeatCodec) Encode(b Body) ([]byte, error) {
+	if _, ok := b.(*HeartbeatBody); !ok {
+		return nil, ErrBodyNotHeartbeat
+	}
+	return nil, nil
+}
+
+func (c *heartbeatCodec) Decode(data []byte) (Body, error) {
+	if len(data) > 0 {
+		return nil, &DecodeError{Offset: 0, Err: ErrTrailingData}
+	}
+	return &HeartbeatBody{}, nil
+}
@@ -0,0 +1,430 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSON layout of a message, field names are snake_case:
+//
+//	{"header": {"message_id": 512, "attr": {...}, "phone": 19161017001, ...},
+//	 "body": {...}}
+//
+// The body is decoded by the header's message id, as the codec does, see
+// RegisterJSONBody. It is {"raw": "<hex>"} for ids this package has no body
+// for and for segments, the message then has "raw_body": true.
+// Location reports have the signed coordinates in degrees, alarm and status
+// flags by name, see AlarmFlags.Set() and StatusFlags.Set(), and ISO 8601
+// timestamps, null if the device sent none. Additional infos are
+// {"id": 1, "info": {...}} for the types of this package, other infos,
+// including unknown ones, carry their bytes as
+// {"id": 231, "raw_info": true, "raw": "<hex>"}.
+// Parameters are {"id": 1, "name": "heartbeat_interval", "value": 30}, or
+// have their bytes as "raw" instead of "value" if Value is a []byte.
+//
+// The identifier and the checksum are left out, encoding sets them.
+
+var (
+	ErrJSONBody = errors.New("json body does not match the message id")
+	ErrJSONInfo = errors.New("json additional info is not raw and has an unknown id")
+)
+
+var jsonAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// jsonBodies are the bodies decoded from JSON by message id
+var jsonBodies = map[uint16]func() Body{
+	MessageIdTerminalResponse:              func() Body { return &TerminalResponse{} },
+	MessageIdHeartbeat:                     func() Body { return &HeartbeatBody{} },
+	MessageIdTerminalRetransmissionRequest: func() Body { return &RetransmissionRequest{} },
+	MessageIdTerminalRegister:              func() Body { return &RegisterMsgBody{} },
+	MessageIdTerminalAuth:                  func() Body { return &AuthMsgBody{} },
+	MessageIdParamsResponse:                func() Body { return &ParamsResponse{} },
+	MessageIdLocationReport:                func() Body { return &LocationMsgBody{} },
+	MessageIdLocationQueryResponse:         func() Body { return &LocationQueryResponse{} },
+	MessageIdBatchLocationReport:           func() Body { return &BatchLocationBody{} },
+	MessageIdServerResponse:                func() Body { return &ServerResponse{} },
+	MessageIdRetransmissionRequest:         func() Body { return &RetransmissionRequest{} },
+	MessageIdRegisterResponse:              func() Body { return &RegisterResponse{} },
+	MessageIdSetParams:                     func() Body { return &SetParams{} },
+	MessageIdQueryParams:                   func() Body { return &QueryParams{} },
+	MessageIdTerminalControl:               func() Body { return &TerminalControl{} },
+	MessageIdQuerySpecificParams:           func() Body { return &QuerySpecificParams{} },
+	MessageIdLocationQuery:                 func() Body { return &LocationQuery{} },
+	MessageIdTemporaryTracking:             func() Body { return &TemporaryTracking{} },
+}
+
+var jsonBodiesMu sync.RWMutex
+
+// RegisterJSONBody sets the body Message.UnmarshalJSON decodes for message
+// id, e.g. the body of a codec registered with Codec.RegisterBodyCodec
+func RegisterJSONBody(id uint16, newBody func() Body) {
+	jsonBodiesMu.Lock()
+	defer jsonBodiesMu.Unlock()
+
+	jsonBodies[id] = newBody
+}
+
+func jsonBody(id uint16) (func() Body, bool) {
+	jsonBodiesMu.RLock()
+	defer jsonBodiesMu.RUnlock()
+
+	newBody, ok := jsonBodies[id]
+	return newBody, ok
+}
+
+// jsonInfos are the additional infos decoded from JSON by id
+var jsonInfos = map[uint8]func() LocationAdditionalInfo{
+	InfoIdMileage:          func() LocationAdditionalInfo { return &Mileage{} },
+	InfoIdFuel:             func() LocationAdditionalInfo { return &Fuel{} },
+	InfoIdRecorderSpeed:    func() LocationAdditionalInfo { return &RecorderSpeed{} },
+	InfoIdAlarmEventId:     func() LocationAdditionalInfo { return &AlarmEventId{} },
+	InfoIdOverspeed:        func() LocationAdditionalInfo { return &OverspeedInfo{} },
+	InfoIdArea:             func() LocationAdditionalInfo { return &AreaInfo{} },
+	InfoIdRouteDrivingTime: func() LocationAdditionalInfo { return &RouteDrivingTime{} },
+	InfoIdVehicleSignals:   func() LocationAdditionalInfo { return &VehicleSignals{} },
+	InfoIdIOStatus:         func() LocationAdditionalInfo { return &IOStatus{} },
+	InfoIdAnalog:           func() LocationAdditionalInfo { return &Analog{} },
+	InfoIdSignalStrength:   func() LocationAdditionalInfo { return &SignalStrength{} },
+	InfoIdSatelliteCount:   func() LocationAdditionalInfo { return &SatelliteCount{} },
+	0x54:                   func() LocationAdditionalInfo { return &AdditionalInfoWifis{} },
+	0x56:                   func() LocationAdditionalInfo { return &Battery{} },
+	0xef:                   func() LocationAdditionalInfo { return &AdditionalInfoCells{} },
+}
+
+// hexBytes is encoded as a hex string
+type hexBytes []byte
+
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	return jsonAPI.Marshal(hex.EncodeToString(h))
+}
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := jsonAPI.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+type jsonMessage struct {
+	H *Header             `json:"header"`
+	B jsoniter.RawMessage `json:"body"`
+	// B is a RawBody
+	RawBody bool `json:"raw_body,omitempty"`
+}
+
+// MarshalJSON encodes the message in the layout described at the top of
+// json.go
+func (m *Message) MarshalJSON() ([]byte, error) {
+	body, err := jsonAPI.Marshal(m.B)
+	if err != nil {
+		return nil, err
+	}
+	_, raw := m.B.(*RawBody)
+	return jsonAPI.Marshal(&jsonMessage{H: m.H, B: body, RawBody: raw})
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON, ready to be encoded
+// by a Codec
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var msg jsonMessage
+	if err := jsonAPI.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg.H == nil {
+		return ErrDecodeHeaderFailed
+	}
+
+	var body Body
+	newBody, ok := jsonBody(msg.H.MessageId)
+	switch {
+	case msg.RawBody:
+		body = &RawBody{}
+	case ok:
+		body = newBody()
+	default:
+		return ErrJSONBody
+	}
+	if err := jsonAPI.Unmarshal(msg.B, body); err != nil {
+		return err
+	}
+
+	*m = Message{H: msg.H, B: body}
+	return nil
+}
+
+// Map returns the JSON encoding of the message as a map, numbers are
+// json.Number so that 64 bit phones stay exact
+func (m *Message) Map() (map[string]interface{}, error) {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]interface{}
+	var dec = jsonAPI.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *RawBody) MarshalJSON() ([]byte, error) {
+	return jsonAPI.Marshal(&struct {
+		Raw hexBytes `json:"raw"`
+	}{r.Data})
+}
+
+func (r *RawBody) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Raw hexBytes `json:"raw"`
+	}
+	if err := jsonAPI.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Data = raw.Raw
+	return nil
+}
+
+type jsonBasicInfo struct {
+	Alarm     AlarmFlags  `json:"alarm"`
+	Status    StatusFlags `json:"status"`
+	Latitude  float64     `json:"latitude"`
+	Longitude float64     `json:"longitude"`
+	// meters
+	Altitude uint16 `json:"altitude"`
+	// 1/10 km/h
+	Speed     uint16     `json:"speed"`
+	Direction uint16     `json:"direction"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+func (b *BasicInfo) MarshalJSON() ([]byte, error) {
+	var basic = jsonBasicInfo{
+		Alarm:     b.Alert,
+		Status:    b.State,
+		Latitude:  b.Lat(),
+		Longitude: b.Lon(),
+		Altitude:  b.Altitude,
+		Speed:     b.Speed,
+		Direction: b.Direction,
+	}
+	if !b.Timestamp.IsZero() {
+		basic.Timestamp = &b.Timestamp
+	}
+	return jsonAPI.Marshal(&basic)
+}
+
+// UnmarshalJSON sets the south latitude and west longitude flags by the sign
+// of the coordinates, whatever the status names
+func (b *BasicInfo) UnmarshalJSON(data []byte) error {
+	var basic jsonBasicInfo
+	if err := jsonAPI.Unmarshal(data, &basic); err != nil {
+		return err
+	}
+
+	*b = BasicInfo{
+		Alert:     basic.Alarm,
+		State:     basic.Status,
+		Altitude:  basic.Altitude,
+		Speed:     basic.Speed,
+		Direction: basic.Direction,
+	}
+	if basic.Timestamp != nil {
+		b.Timestamp = *basic.Timestamp
+	}
+
+	if err := b.SetLat(basic.Latitude); err != nil {
+		return err
+	}
+	if err := b.SetLon(basic.Longitude); err != nil {
+		return err
+	}
+	// -0 is south or west too
+	if math.Signbit(basic.Latitude) {
+		b.State |= StatusSouthLatitude
+	}
+	if math.Signbit(basic.Longitude) {
+		b.State |= StatusWestLongitude
+	}
+	return nil
+}
+
+type jsonInfo struct {
+	Id   uint8               `json:"id"`
+	Info jsoniter.RawMessage `json:"info,omitempty"`
+	// decoded from Raw as an UnknownInfo
+	RawInfo bool     `json:"raw_info,omitempty"`
+	Raw     hexBytes `json:"raw,omitempty"`
+}
+
+func marshalInfo(info LocationAdditionalInfo) (*jsonInfo, error) {
+	var res = jsonInfo{Id: info.Id()}
+
+	if newInfo, ok := jsonInfos[info.Id()]; !ok || reflect.TypeOf(newInfo()) != reflect.TypeOf(info) {
+		// raw bytes are enough to encode it again, a vendor info keeps its
+		// own JSON too
+		res.RawInfo = true
+		res.Raw = info.Info()
+		if _, ok := info.(*UnknownInfo); ok {
+			return &res, nil
+		}
+	}
+
+	var err error
+	res.Info, err = jsonAPI.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func unmarshalInfo(ji *jsonInfo) (LocationAdditionalInfo, error) {
+	if ji.RawInfo {
+		return NewUnknownInfo(ji.Id, ji.Raw), nil
+	}
+
+	newInfo, ok := jsonInfos[ji.Id]
+	if !ok || len(ji.Info) == 0 {
+		return nil, ErrJSONInfo
+	}
+	info := newInfo()
+	if err := jsonAPI.Unmarshal(ji.Info, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type jsonLocationMsgBody struct {
+	Basic           *BasicInfo  `json:"basic"`
+	AdditionalInfos []*jsonInfo `json:"additional_infos"`
+	Backfilled      bool        `json:"backfilled,omitempty"`
+}
+
+func (l *LocationMsgBody) MarshalJSON() ([]byte, error) {
+	var body = jsonLocationMsgBody{
+		Basic:           l.Basic,
+		AdditionalInfos: make([]*jsonInfo, 0, len(l.AdditionalInfos)),
+		Backfilled:      l.Backfilled,
+	}
+	for i := range l.AdditionalInfos {
+		info, err := marshalInfo(l.AdditionalInfos[i])
+		if err != nil {
+			return nil, err
+		}
+		body.AdditionalInfos = append(body.AdditionalInfos, info)
+	}
+	return jsonAPI.Marshal(&body)
+}
+
+func (l *LocationMsgBody) UnmarshalJSON(data []byte) error {
+	var body jsonLocationMsgBody
+	if err := jsonAPI.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	if body.Basic == nil {
+		return ErrJSONBody
+	}
+
+	*l = LocationMsgBody{
+		Basic:      body.Basic,
+		Backfilled: body.Backfilled,
+	}
+	for _, ji := range body.AdditionalInfos {
+		info, err := unmarshalInfo(ji)
+		if err != nil {
+			return err
+		}
+		l.AdditionalInfos = append(l.AdditionalInfos, info)
+	}
+	return nil
+}
+
+func (u *UnknownInfo) MarshalJSON() ([]byte, error) {
+	return jsonAPI.Marshal(&jsonInfo{Id: u.id, RawInfo: true, Raw: u.body})
+}
+
+func (u *UnknownInfo) UnmarshalJSON(data []byte) error {
+	var ji jsonInfo
+	if err := jsonAPI.Unmarshal(data, &ji); err != nil {
+		return err
+	}
+	*u = *NewUnknownInfo(ji.Id, ji.Raw)
+	return nil
+}
+
+type jsonParam struct {
+	Id    uint32              `json:"id"`
+	Name  string              `json:"name"`
+	Value jsoniter.RawMessage `json:"value,omitempty"`
+	Raw   hexBytes            `json:"raw,omitempty"`
+}
+
+func (p *Param) MarshalJSON() ([]byte, error) {
+	var param = jsonParam{Id: p.Id, Name: p.Name()}
+
+	if b, ok := p.Value.([]byte); ok {
+		param.Raw = b
+		if param.Raw == nil {
+			param.Raw = []byte{}
+		}
+		return jsonAPI.Marshal(&param)
+	}
+
+	var err error
+	param.Value, err = jsonAPI.Marshal(p.Value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonAPI.Marshal(&param)
+}
+
+// UnmarshalJSON decodes the value as the type of the id, the name is ignored
+func (p *Param) UnmarshalJSON(data []byte) error {
+	var param jsonParam
+	if err := jsonAPI.Unmarshal(data, &param); err != nil {
+		return err
+	}
+
+	*p = Param{Id: param.Id}
+	if param.Raw != nil || len(param.Value) == 0 {
+		p.Value = []byte(param.Raw)
+		return nil
+	}
+
+	var err error
+	switch ParamTypeOf(p.Id) {
+	case ParamDword:
+		var v uint32
+		err = jsonAPI.Unmarshal(param.Value, &v)
+		p.Value = v
+	case ParamWord:
+		var v uint16
+		err = jsonAPI.Unmarshal(param.Value, &v)
+		p.Value = v
+	case ParamByte:
+		var v uint8
+		err = jsonAPI.Unmarshal(param.Value, &v)
+		p.Value = v
+	case ParamString:
+		var v string
+		err = jsonAPI.Unmarshal(param.Value, &v)
+		p.Value = v
+	default:
+		return ErrParamType
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestMessage_JSON(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	encoded, err := c.Encode(msg)
+	assert.Equal(t, nil, err)
+
+	js, err := jsonAPI.Marshal(msg)
+	assert.Equal(t, nil, err)
+
+	var decoded Message
+	assert.Equal(t, nil, jsonAPI.Unmarshal(js, &decoded))
+	reencoded, err := c.Encode(&decoded)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, encoded, reencoded)
+
+	m, err := msg.Map()
+	assert.Equal(t, nil, err)
+	header := m["header"].(map[string]interface{})
+	assert.Equal(t, json.Number("19161017001"), header["phone"])
+	body := m["body"].(map[string]interface{})
+	basic := body["basic"].(map[string]interface{})
+	assert.Equal(t, "2016-10-17T10:27:56+08:00", basic["timestamp"])
+	for _, info := range body["additional_infos"].([]interface{}) {
+		info := info.(map[string]interface{})
+		_, typed := info["info"]
+		_, raw := info["raw"]
+		assert.Equal(t, true, typed != raw)
+	}
+}
+
+func TestBasicInfo_JSON(t *testing.T) {
+	var basic = BasicInfo{
+		Alert:     AlarmEmergency | AlarmOverspeed,
+		State:     StatusACCOn | StatusPositioned,
+		Altitude:  12,
+		Speed:     605,
+		Direction: 90,
+	}
+	assert.Equal(t, nil, basic.SetLat(-33.868820))
+	assert.Equal(t, nil, basic.SetLon(151.209296))
+
+	js, err := jsonAPI.Marshal(&basic)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"alarm":["emergency","overspeed"],"status":["acc_on","positioned","south_latitude"],`+
+		`"latitude":-33.86882,"longitude":151.209296,"altitude":12,"speed":605,"direction":90,"timestamp":null}`, string(js))
+
+	var decoded BasicInfo
+	assert.Equal(t, nil, jsonAPI.Unmarshal(js, &decoded))
+	assert.Equal(t, basic, decoded)
+
+	// the sign of the coordinates wins over the status names
+	js = []byte(`{"status":["west_longitude"],"latitude":-0,"longitude":1,"timestamp":"2020-01-02T03:04:05Z"}`)
+	assert.Equal(t, nil, jsonAPI.Unmarshal(js, &decoded))
+	assert.Equal(t, StatusSouthLatitude, decoded.State)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), decoded.Timestamp)
+
+	assert.Equal(t, ErrInvalidCoordinate, decoded.UnmarshalJSON([]byte(`{"latitude":91}`)))
+}
+
+func TestMessage_JSONBodies(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	sample, _ := c.Decode(data)
+	location := sample.B.(*LocationMsgBody)
+
+	for _, msg := range []*Message{
+		{H: &Header{MessageId: MessageIdBatchLocationReport, Phone: 13800138000}, B: &BatchLocationBody{
+			Type:  BatchLocationBackfill,
+			Items: []*LocationMsgBody{location, location},
+		}},
+		{H: &Header{MessageId: MessageIdLocationQueryResponse, Phone: 13800138000},
+			B: &LocationQueryResponse{SerialNum: 3, Location: location}},
+		{H: &Header{MessageId: MessageIdSetParams, Phone: 13800138000}, B: &SetParams{Params: []*Param{
+			{Id: ParamIdHeartbeatInterval, Value: uint32(30)},
+			{Id: ParamIdMainServerAddress, Value: "example.com"},
+			{Id: ParamIdPlateColor, Value: uint8(2)},
+			{Id: 0xf001, Value: []byte{0x01, 0x02}},
+		}}},
+		{H: &Header{MessageId: MessageIdTerminalControl, Phone: 13800138000}, B: &TerminalControl{
+			Command: ControlConnectServer,
+			Connect: &ConnectParams{Control: ConnectSpecifiedServer, AuthCode: "abc", TCPPort: 7611},
+		}},
+		{H: &Header{MessageId: MessageIdServerResponse, Version: Version2019, Phone: 13800138000},
+			B: &ServerResponse{SerialNum: 7, ID: MessageIdLocationReport, Result: ResultSuccess}},
+		{H: &Header{MessageId: 0x0900, Phone: 13800138000}, B: &RawBody{Data: []byte{0xde, 0xad}}},
+	} {
+		encoded, err := c.Encode(msg)
+		assert.Equal(t, nil, err)
+
+		js, err := jsonAPI.Marshal(msg)
+		assert.Equal(t, nil, err)
+		var decoded Message
+		assert.Equal(t, nil, jsonAPI.Unmarshal(js, &decoded))
+		reencoded, err := c.Encode(&decoded)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, encoded, reencoded)
+	}
+
+	var decoded Message
+	js := `{"header":{"message_id":2304},"body":{"value":1}}`
+	assert.Equal(t, ErrJSONBody, decoded.UnmarshalJSON([]byte(js)))
+	var locationBody LocationMsgBody
+	js = `{"basic":{},"additional_infos":[{"id":231,"info":{}}]}`
+	assert.Equal(t, ErrJSONInfo, locationBody.UnmarshalJSON([]byte(js)))
+}
+
+type rawFieldBody struct {
+	Raw string `json:"raw"`
+}
+
+func (b *rawFieldBody) Human() string {
+	return b.Raw
+}
+
+func TestRegisterJSONBody(t *testing.T) {
+	// a body with a field named raw is not taken for a RawBody
+	var msg = &Message{H: &Header{MessageId: 0x0f02, Phone: 13800138000}, B: &rawFieldBody{Raw: "abc"}}
+	js, err := jsonAPI.Marshal(msg)
+	assert.Equal(t, nil, err)
+
+	var decoded Message
+	assert.Equal(t, ErrJSONBody, decoded.UnmarshalJSON(js))
+	RegisterJSONBody(0x0f02, func() Body { return &rawFieldBody{} })
+	assert.Equal(t, nil, decoded.UnmarshalJSON(js))
+	assert.Equal(t, msg.B, decoded.B)
+
+	// segments of registered ids stay raw
+	msg.B = &RawBody{Data: []byte{0xab}}
+	js, err = jsonAPI.Marshal(msg)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, decoded.UnmarshalJSON(js))
+	assert.Equal(t, msg.B, decoded.B)
+}
+
+func TestLocationMsgBody_JSONRawInfos(t *testing.T) {
+	var body = LocationMsgBody{
+		Basic:           &BasicInfo{},
+		AdditionalInfos: []LocationAdditionalInfo{NewUnknownInfo(0xe1, []byte{}), NewUnknownInfo(0x01, []byte{0x01})},
+	}
+	js, err := jsonAPI.Marshal(&body)
+	assert.Equal(t, nil, err)
+
+	var decoded LocationMsgBody
+	assert.Equal(t, nil, decoded.UnmarshalJSON(js))
+	assert.Equal(t, NewUnknownInfo(0xe1, nil), decoded.AdditionalInfos[0])
+	assert.Equal(t, body.AdditionalInfos[1], decoded.AdditionalInfos[1])
+
+	// raw bytes without the marker are not an unknown info
+	js = []byte(`{"basic":{},"additional_infos":[{"id":1,"info":{"value":5},"raw":"00"}]}`)
+	assert.Equal(t, nil, decoded.UnmarshalJSON(js))
+	assert.Equal(t, &Mileage{Value: 5}, decoded.AdditionalInfos[0])
+}
+
+func TestParam_JSON(t *testing.T) {
+	js, err := jsonAPI.Marshal([]*Param{
+		{Id: ParamIdHeartbeatInterval, Value: uint32(30)},
+		{Id: 0xf001, Value: []byte{0x01, 0x02}},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `[{"id":1,"name":"heartbeat_interval","value":30},{"id":61441,"name":"0xf001","raw":"0102"}]`, string(js))
+
+	var p Param
+	assert.Equal(t, nil, p.UnmarshalJSON([]byte(`{"id":1,"name":"ignored","value":30}`)))
+	assert.Equal(t, Param{Id: ParamIdHeartbeatInterval, Value: uint32(30)}, p)
+	assert.NotEqual(t, nil, p.UnmarshalJSON([]byte(`{"id":1,"value":"30"}`)))
+	assert.NotEqual(t, nil, p.UnmarshalJSON([]byte(`{"id":61441,"value":30}`)))
+}
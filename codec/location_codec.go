@@ -3,12 +3,18 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"github.com/sceneryback/jtt808/utils"
-	"strings"
+	"math"
 	"time"
 )
 
+var (
+	ErrBodyNotLocation   = errors.New("body is not location report")
+	ErrInvalidCoordinate = errors.New("latitude or longitude out of range")
+	ErrMissingBasicInfo  = errors.New("location has no basic info")
+)
+
 const (
 	LocationBasicInfoLength = 28
 
@@ -18,9 +24,14 @@ const (
 )
 
 type locationBasicInfoCodec struct {
+	// time zone of timestamps, DefaultTimeZone if nil
+	loc *time.Location
 }
 
 type locationAdditionalInfoCodec struct {
+	// default decoders only if nil
+	registry *additionalInfoRegistry
+	vendor   string
 }
 
 type locationCodec struct {
@@ -36,117 +47,191 @@ type LocationAdditionalInfo interface {
 }
 
 type BasicInfo struct {
-	Alert     uint32
-	State     uint32
+	Alert     AlarmFlags
+	State     StatusFlags
 	Latitude  uint32
 	Longitude uint32
 	Altitude  uint16
 	Speed     uint16
 	Direction uint16
-	Timestamp int64
+	// zero if the device sent an all-zero timestamp
+	Timestamp time.Time
 }
 
-func (l *locationBasicInfoCodec) Decode(data []byte) (*BasicInfo, error) {
-	var basic BasicInfo
-
-	err := binary.Read(bytes.NewReader(data[:4]), binary.BigEndian, &basic.Alert)
-	if err != nil {
-		return nil, err
+// Lat returns the latitude in degrees, negative in the southern hemisphere
+func (b *BasicInfo) Lat() float64 {
+	lat := float64(b.Latitude) / 1e6
+	if b.State.SouthLatitude() {
+		return -lat
 	}
+	return lat
+}
 
-	err = binary.Read(bytes.NewReader(data[4:8]), binary.BigEndian, &basic.State)
-	if err != nil {
-		return nil, err
+// Lon returns the longitude in degrees, negative in the western hemisphere
+func (b *BasicInfo) Lon() float64 {
+	lon := float64(b.Longitude) / 1e6
+	if b.State.WestLongitude() {
+		return -lon
 	}
+	return lon
+}
 
-	err = binary.Read(bytes.NewReader(data[8:12]), binary.BigEndian, &basic.Latitude)
-	if err != nil {
-		return nil, err
+// SetLat sets the latitude and the south latitude state bit
+func (b *BasicInfo) SetLat(lat float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return ErrInvalidCoordinate
 	}
+	b.Latitude = uint32(math.Round(math.Abs(lat) * 1e6))
+	if lat < 0 {
+		b.State |= StatusSouthLatitude
+	} else {
+		b.State &^= StatusSouthLatitude
+	}
+	return nil
+}
 
-	err = binary.Read(bytes.NewReader(data[12:16]), binary.BigEndian, &basic.Longitude)
-	if err != nil {
-		return nil, err
+// SetLon sets the longitude and the west longitude state bit
+func (b *BasicInfo) SetLon(lon float64) error {
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return ErrInvalidCoordinate
+	}
+	b.Longitude = uint32(math.Round(math.Abs(lon) * 1e6))
+	if lon < 0 {
+		b.State |= StatusWestLongitude
+	} else {
+		b.State &^= StatusWestLongitude
 	}
+	return nil
+}
 
-	err = binary.Read(bytes.NewReader(data[16:18]), binary.BigEndian, &basic.Altitude)
-	if err != nil {
-		return nil, err
+func (l *locationBasicInfoCodec) Encode(basic *BasicInfo) ([]byte, error) {
+	return l.appendBasic(make([]byte, 0, LocationBasicInfoLength), basic), nil
+}
+
+func (l *locationBasicInfoCodec) appendBasic(dst []byte, basic *BasicInfo) []byte {
+	dst = appendDword(dst, uint32(basic.Alert))
+	dst = appendDword(dst, uint32(basic.State))
+	dst = appendDword(dst, basic.Latitude)
+	dst = appendDword(dst, basic.Longitude)
+	dst = appendWord(dst, basic.Altitude)
+	dst = appendWord(dst, basic.Speed)
+	dst = appendWord(dst, basic.Direction)
+	return appendBCDTime(dst, basic.Timestamp, l.loc)
+}
+
+func (l *locationBasicInfoCodec) Decode(data []byte) (*BasicInfo, error) {
+	if len(data) < LocationBasicInfoLength {
+		return nil, &DecodeError{Offset: len(data), Err: ErrTruncated}
 	}
 
-	err = binary.Read(bytes.NewReader(data[18:20]), binary.BigEndian, &basic.Speed)
-	if err != nil {
-		return nil, err
+	var basic = BasicInfo{
+		Alert:     AlarmFlags(binary.BigEndian.Uint32(data[0:])),
+		State:     StatusFlags(binary.BigEndian.Uint32(data[4:])),
+		Latitude:  binary.BigEndian.Uint32(data[8:]),
+		Longitude: binary.BigEndian.Uint32(data[12:]),
+		Altitude:  binary.BigEndian.Uint16(data[16:]),
+		Speed:     binary.BigEndian.Uint16(data[18:]),
+		Direction: binary.BigEndian.Uint16(data[20:]),
 	}
 
-	err = binary.Read(bytes.NewReader(data[20:22]), binary.BigEndian, &basic.Direction)
-	if err != nil {
-		return nil, err
+	if basic.Latitude > 90e6 || basic.Longitude > 180e6 {
+		return nil, ErrInvalidCoordinate
 	}
 
-	tstr := strings.TrimPrefix(utils.DecodeBCD(data[22:]), "0")
-	ts, err := time.Parse(TimeFormat, "20"+tstr+"+0800")
+	var err error
+	basic.Timestamp, err = decodeBCDTime(data[22:LocationBasicInfoLength], l.loc)
 	if err != nil {
 		return nil, err
 	}
-	basic.Timestamp = ts.Unix()
 
 	return &basic, nil
 }
 
+// every info is id, length and the info itself
+func (l *locationAdditionalInfoCodec) Encode(infos []LocationAdditionalInfo) ([]byte, error) {
+	return l.appendInfos(nil, infos)
+}
+
+func (l *locationAdditionalInfoCodec) appendInfos(dst []byte, infos []LocationAdditionalInfo) ([]byte, error) {
+	for i := range infos {
+		info := infos[i].Info()
+		if len(info) > 0xff {
+			return nil, ErrFieldTooLong
+		}
+		dst = append(dst, infos[i].Id(), uint8(len(info)))
+		dst = append(dst, info...)
+	}
+
+	return dst, nil
+}
+
+// Infos with no decoder, or whose body does not fit their id, are kept as
+// UnknownInfo so one odd info does not fail the whole report.
 func (l *locationAdditionalInfoCodec) Decode(data []byte) ([]LocationAdditionalInfo, error) {
 	var infos []LocationAdditionalInfo
 
 	var singleInfoLength int
 	for i := 0; i < len(data); {
-		switch data[i] {
-		case 0x54:
-			singleInfoLength = int(data[i+1])
-			wifis, err := (&wifiCodec{}).Decode(data[i+2 : i+2+singleInfoLength])
-			if err != nil {
-				return nil, err
-			}
-			infos = append(infos, wifis)
-			i += (2 + singleInfoLength)
-		case 0x56:
-			singleInfoLength = int(data[i+1])
-			batInfo, err := (&batteryCodec{}).Decode(data[i+2 : i+2+singleInfoLength])
-			if err != nil {
-				return nil, err
-			}
-			infos = append(infos, batInfo)
-			i += (2 + singleInfoLength)
-		default:
-			// ignored other infos
-			singleInfoLength = int(data[i+1])
-			info, err := (&unknownInfoCodec{}).Decode(data[i : i+1+singleInfoLength+1])
-			if err != nil {
-				return nil, err
+		if i+2 > len(data) {
+			return nil, &DecodeError{Offset: len(data), Err: ErrTruncated}
+		}
+		singleInfoLength = int(data[i+1])
+		if i+2+singleInfoLength > len(data) {
+			return nil, &DecodeError{Offset: len(data), Err: ErrTruncated}
+		}
+		body := data[i+2 : i+2+singleInfoLength]
+
+		var info LocationAdditionalInfo
+		if decode := l.registry.decoder(l.vendor, data[i]); decode != nil {
+			decoded, err := decode(body)
+			if err == nil {
+				info = decoded
 			}
-			infos = append(infos, info)
-			i += (2 + singleInfoLength)
 		}
+		if info == nil {
+			info = NewUnknownInfo(data[i], body)
+		}
+		infos = append(infos, info)
+		i += (2 + singleInfoLength)
 	}
 
 	return infos, nil
 }
 
+func additionalInfosLength(infos []LocationAdditionalInfo) int {
+	var n int
+	for i := range infos {
+		n += 2 + int(infos[i].Length())
+	}
+	return n
+}
+
 type LocationMsgBody struct {
-	Basic           *BasicInfo
-	AdditionalInfos []LocationAdditionalInfo
+	Basic           *BasicInfo               `json:"basic"`
+	AdditionalInfos []LocationAdditionalInfo `json:"additional_infos"`
+	// set on items of a backfill batch (0x0704), it is not encoded
+	Backfilled bool `json:"backfilled,omitempty"`
 }
 
 func (l *LocationMsgBody) Human() string {
 	var buf bytes.Buffer
 
-	buf.WriteString(fmt.Sprintf("alert: %d\n", l.Basic.Alert))
-	buf.WriteString(fmt.Sprintf("state: %d\n", l.Basic.State))
-	buf.WriteString(fmt.Sprintf("latitude: %f\n", float64(l.Basic.Latitude)/1e6))
-	buf.WriteString(fmt.Sprintf("longitude: %f\n", float64(l.Basic.Longitude)/1e6))
+	buf.WriteString(fmt.Sprintf("alert: %d (%s)\n", uint32(l.Basic.Alert), l.Basic.Alert))
+	buf.WriteString(fmt.Sprintf("state: %d (%s)\n", uint32(l.Basic.State), l.Basic.State))
+	buf.WriteString(fmt.Sprintf("latitude: %f\n", l.Basic.Lat()))
+	buf.WriteString(fmt.Sprintf("longitude: %f\n", l.Basic.Lon()))
 	buf.WriteString(fmt.Sprintf("altitude: %d\n", l.Basic.Altitude))
 	buf.WriteString(fmt.Sprintf("speed: %d\n", l.Basic.Speed))
 	buf.WriteString(fmt.Sprintf("direction: %d\n", l.Basic.Direction))
-	buf.WriteString(fmt.Sprintf("timestamp: %s\n", time.Unix(l.Basic.Timestamp, 0).Format(TimeFormatHuman)))
+	if l.Basic.Timestamp.IsZero() {
+		buf.WriteString("timestamp: unknown\n")
+	} else {
+		buf.WriteString(fmt.Sprintf("timestamp: %s\n", l.Basic.Timestamp.Format(TimeFormatHuman)))
+	}
+
+	if l.Backfilled {
+		buf.WriteString("backfilled: true\n")
+	}
 
 	buf.WriteString("additional infos ===== \n")
 	for i := range l.AdditionalInfos {
@@ -156,15 +241,26 @@ func (l *LocationMsgBody) Human() string {
 	return buf.String()
 }
 
+// 0x0200
 func (l *locationCodec) Encode(b Body) ([]byte, error) {
-	return nil, nil
+	body, ok := b.(*LocationMsgBody)
+	if !ok {
+		return nil, ErrBodyNotLocation
+	}
+	if body == nil || body.Basic == nil {
+		return nil, ErrMissingBasicInfo
+	}
+
+	var res = make([]byte, 0, LocationBasicInfoLength+additionalInfosLength(body.AdditionalInfos))
+	res = l.basic.appendBasic(res, body.Basic)
+
+	return l.ai.appendInfos(res, body.AdditionalInfos)
 }
 
 func (l *locationCodec) Decode(data []byte) (Body, error) {
 	var body LocationMsgBody
 
-	basicBytes := data[:LocationBasicInfoLength]
-	basic, err := l.basic.Decode(basicBytes)
+	basic, err := l.basic.Decode(data)
 	if err != nil {
 		return nil, err
 	}
@@ -173,9 +269,21 @@ func (l *locationCodec) Decode(data []byte) (Body, error) {
 	additionalBytes := data[LocationBasicInfoLength:]
 	additionals, err := l.ai.Decode(additionalBytes)
 	if err != nil {
-		return nil, err
+		return nil, shiftOffset(err, LocationBasicInfoLength)
 	}
 	body.AdditionalInfos = additionals
 
 	return &body, nil
 }
+
+func (l *locationCodec) withTimeZone(loc *time.Location) BodyCodec {
+	var n = *l
+	n.basic.loc = loc
+	return &n
+}
+
+func (l *locationCodec) withVendor(vendor string) BodyCodec {
+	var n = *l
+	n.ai.vendor = vendor
+	return &n
+}
@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrBodyNotLocationQuery         = errors.New("body is not location query")
+	ErrBodyNotLocationQueryResponse = errors.New("body is not location query response")
+	ErrBodyNotTemporaryTracking     = errors.New("body is not temporary tracking control")
+)
+
+// LocationQuery is the empty location query (0x8201) body
+type LocationQuery struct {
+}
+
+func (q *LocationQuery) Human() string {
+	return "location query\n"
+}
+
+type locationQueryCodec struct {
+}
+
+// 0x8201
+func (c *locationQueryCodec) Encode(b Body) ([]byte, error) {
+	if _, ok := b.(*LocationQuery); !ok {
+		return nil, ErrBodyNotLocationQuery
+	}
+	return nil, nil
+}
+
+func (c *locationQueryCodec) Decode(data []byte) (Body, error) {
+	if len(data) > 0 {
+		return nil, &DecodeError{Offset: 0, Err: ErrTrailingData}
+	}
+	return &LocationQuery{}, nil
+}
+
+// LocationQueryResponse is the terminal's answer (0x0201) to a location query
+type LocationQueryResponse struct {
+	// serial num of the 0x8201 query
+	SerialNum uint16           `json:"serial_num"`
+	Location  *LocationMsgBody `json:"location"`
+}
+
+func (r *LocationQueryResponse) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("serial num: %d\n", r.SerialNum))
+	buf.WriteString(r.Location.Human())
+
+	return buf.String()
+}
+
+// locationQueryResponseCodec encodes the location with the location report
+// codec
+type locationQueryResponseCodec struct {
+	location locationCodec
+}
+
+// 0x0201
+func (c *locationQueryResponseCodec) Encode(b Body) ([]byte, error) {
+	r, ok := b.(*LocationQueryResponse)
+	if !ok {
+		return nil, ErrBodyNotLocationQueryResponse
+	}
+
+	location, err := c.location.Encode(r.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	var res = make([]byte, 0, 2+len(location))
+	res = appendWord(res, r.SerialNum)
+	return append(res, location...), nil
+}
+
+func (c *locationQueryResponseCodec) Decode(data []byte) (Body, error) {
+	br := newBodyReader(data)
+	serialNum := br.word()
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	location, err := c.location.Decode(br.rest())
+	if err != nil {
+		return nil, shiftOffset(err, 2)
+	}
+
+	return &LocationQueryResponse{
+		SerialNum: serialNum,
+		Location:  location.(*LocationMsgBody),
+	}, nil
+}
+
+func (c *locationQueryResponseCodec) withTimeZone(loc *time.Location) BodyCodec {
+	var n = *c
+	n.location.basic.loc = loc
+	return &n
+}
+
+func (c *locationQueryResponseCodec) withVendor(vendor string) BodyCodec {
+	var n = *c
+	n.location.ai.vendor = vendor
+	return &n
+}
+
+// TemporaryTracking controls temporary location tracking (0x8202), the
+// terminal reports every Interval seconds for Validity seconds. A zero
+// Interval stops tracking.
+type TemporaryTracking struct {
+	Interval uint16 `json:"interval"`
+	Validity uint32 `json:"validity,omitempty"`
+}
+
+func (t *TemporaryTracking) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("interval: %ds\n", t.Interval))
+	buf.WriteString(fmt.Sprintf("validity: %ds\n", t.Validity))
+
+	return buf.String()
+}
+
+type temporaryTrackingCodec struct {
+}
+
+// 0x8202
+func (c *temporaryTrackingCodec) Encode(b Body) ([]byte, error) {
+	t, ok := b.(*TemporaryTracking)
+	if !ok {
+		return nil, ErrBodyNotTemporaryTracking
+	}
+
+	var res = appendWord(make([]byte, 0, 6), t.Interval)
+	if t.Interval != 0 {
+		res = appendDword(res, t.Validity)
+	}
+
+	return res, nil
+}
+
+// Decode expects the validity left out when tracking is stopped, as Encode
+// does
+func (c *temporaryTrackingCodec) Decode(data []byte) (Body, error) {
+	var t TemporaryTracking
+
+	br := newBodyReader(data)
+	t.Interval = br.word()
+	if t.Interval != 0 {
+		t.Validity = br.dword()
+	}
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"encoding/hex"
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestLocationQueryCodecs(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var location = &LocationMsgBody{
+		Basic: &BasicInfo{
+			Latitude:  22543096,
+			Longitude: 114057865,
+			Timestamp: time.Date(2016, 10, 17, 10, 27, 56, 0, DefaultTimeZone),
+		},
+		AdditionalInfos: []LocationAdditionalInfo{&SatelliteCount{Value: 9}},
+	}
+
+	for _, b := range []struct {
+		id   uint16
+		body Body
+	}{
+		{MessageIdLocationQuery, &LocationQuery{}},
+		{MessageIdTemporaryTracking, &TemporaryTracking{Interval: 10, Validity: 3600}},
+		{MessageIdTemporaryTracking, &TemporaryTracking{}},
+		{MessageIdLocationQueryResponse, &LocationQueryResponse{SerialNum: 12, Location: location}},
+	} {
+		data, err := c.Encode(&Message{
+			H: &Header{MessageId: b.id, Phone: 19161017001},
+			B: b.body,
+		})
+		assert.Equal(t, nil, err)
+
+		msg, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, b.body, msg.B)
+	}
+}
+
+func TestTemporaryTrackingCodec_Encode(t *testing.T) {
+	var c temporaryTrackingCodec
+
+	data, err := c.Encode(&TemporaryTracking{Interval: 10, Validity: 3600})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "000a00000e10", hex.EncodeToString(data))
+
+	// stopping tracking leaves the validity out
+	data, err = c.Encode(&TemporaryTracking{Validity: 3600})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0000", hex.EncodeToString(data))
+}
@@ -0,0 +1,235 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBodyNotSetParams           = errors.New("body is not set terminal params")
+	ErrBodyNotQueryParams         = errors.New("body is not query terminal params")
+	ErrBodyNotQuerySpecificParams = errors.New("body is not query specific terminal params")
+	ErrBodyNotParamsResponse      = errors.New("body is not query terminal params response")
+)
+
+// SetParams sets terminal parameters (0x8103), the terminal answers with a
+// terminal response
+type SetParams struct {
+	Params []*Param `json:"params"`
+}
+
+func (s *SetParams) Human() string {
+	return paramsHuman(s.Params)
+}
+
+// QueryParams is the empty query all terminal params (0x8104) body
+type QueryParams struct {
+}
+
+func (q *QueryParams) Human() string {
+	return "query params\n"
+}
+
+// QuerySpecificParams queries the terminal params of Ids (0x8106)
+type QuerySpecificParams struct {
+	Ids []uint32 `json:"ids"`
+}
+
+func (q *QuerySpecificParams) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("query params:")
+	for _, id := range q.Ids {
+		buf.WriteString(fmt.Sprintf(" %s", (&Param{Id: id}).Name()))
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
+
+// ParamsResponse is the terminal's answer (0x0104) to 0x8104 and 0x8106
+type ParamsResponse struct {
+	// serial num of the query
+	SerialNum uint16   `json:"serial_num"`
+	Params    []*Param `json:"params"`
+}
+
+func (r *ParamsResponse) Human() string {
+	return fmt.Sprintf("serial num: %d\n", r.SerialNum) + paramsHuman(r.Params)
+}
+
+// Get returns the first param with id, or nil
+func (r *ParamsResponse) Get(id uint32) *Param {
+	for _, p := range r.Params {
+		if p.Id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func paramsHuman(params []*Param) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("params: %d\n", len(params)))
+	for _, p := range params {
+		buf.WriteString(p.Human())
+	}
+
+	return buf.String()
+}
+
+// every param is DWORD id, BYTE length and the value, after a BYTE count
+func appendParams(dst []byte, params []*Param) ([]byte, error) {
+	if len(params) > 0xff {
+		return nil, ErrFieldTooLong
+	}
+	dst = append(dst, uint8(len(params)))
+
+	for _, p := range params {
+		if p == nil {
+			return nil, ErrNilParam
+		}
+		value, err := p.encodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > 0xff {
+			return nil, ErrFieldTooLong
+		}
+		dst = appendDword(dst, p.Id)
+		dst = append(dst, uint8(len(value)))
+		dst = append(dst, value...)
+	}
+
+	return dst, nil
+}
+
+func decodeParams(br *bodyReader) ([]*Param, error) {
+	var params []*Param
+
+	count := int(br.byte())
+	for i := 0; i < count && br.err == nil; i++ {
+		id := br.dword()
+		data := br.bytes(int(br.byte()))
+		if br.err != nil {
+			break
+		}
+
+		value, err := decodeParamValue(id, data)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, &Param{Id: id, Value: value})
+	}
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+type setParamsCodec struct {
+}
+
+// 0x8103
+func (c *setParamsCodec) Encode(b Body) ([]byte, error) {
+	s, ok := b.(*SetParams)
+	if !ok {
+		return nil, ErrBodyNotSetParams
+	}
+
+	return appendParams(nil, s.Params)
+}
+
+func (c *setParamsCodec) Decode(data []byte) (Body, error) {
+	params, err := decodeParams(newBodyReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetParams{Params: params}, nil
+}
+
+type queryParamsCodec struct {
+}
+
+// 0x8104
+func (c *queryParamsCodec) Encode(b Body) ([]byte, error) {
+	if _, ok := b.(*QueryParams); !ok {
+		return nil, ErrBodyNotQueryParams
+	}
+	return nil, nil
+}
+
+func (c *queryParamsCodec) Decode(data []byte) (Body, error) {
+	if len(data) > 0 {
+		return nil, &DecodeError{Offset: 0, Err: ErrTrailingData}
+	}
+	return &QueryParams{}, nil
+}
+
+type querySpecificParamsCodec struct {
+}
+
+// 0x8106
+func (c *querySpecificParamsCodec) Encode(b Body) ([]byte, error) {
+	q, ok := b.(*QuerySpecificParams)
+	if !ok {
+		return nil, ErrBodyNotQuerySpecificParams
+	}
+	if len(q.Ids) > 0xff {
+		return nil, ErrFieldTooLong
+	}
+
+	var res = make([]byte, 0, 1+4*len(q.Ids))
+	res = append(res, uint8(len(q.Ids)))
+	for _, id := range q.Ids {
+		res = appendDword(res, id)
+	}
+
+	return res, nil
+}
+
+func (c *querySpecificParamsCodec) Decode(data []byte) (Body, error) {
+	var q QuerySpecificParams
+
+	br := newBodyReader(data)
+	count := int(br.byte())
+	for i := 0; i < count && br.err == nil; i++ {
+		q.Ids = append(q.Ids, br.dword())
+	}
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+type paramsResponseCodec struct {
+}
+
+// 0x0104
+func (c *paramsResponseCodec) Encode(b Body) ([]byte, error) {
+	r, ok := b.(*ParamsResponse)
+	if !ok {
+		return nil, ErrBodyNotParamsResponse
+	}
+
+	return appendParams(appendWord(nil, r.SerialNum), r.Params)
+}
+
+func (c *paramsResponseCodec) Decode(data []byte) (Body, error) {
+	var r ParamsResponse
+
+	br := newBodyReader(data)
+	r.SerialNum = br.word()
+	params, err := decodeParams(br)
+	if err != nil {
+		return nil, err
+	}
+	r.Params = params
+
+	return &r, nil
+}
@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"encoding/hex"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestParamCodecs_RoundTrip(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var params = []*Param{
+		{Id: ParamIdHeartbeatInterval, Value: uint32(30)},
+		{Id: ParamIdMainServerAddress, Value: "gps.example.com"},
+		{Id: ParamIdFenceRadius, Value: uint16(500)},
+		{Id: ParamIdPlateColor, Value: uint8(2)},
+		{Id: ParamIdPlateNumber, Value: "粤B12345"},
+		{Id: 0xf001, Value: []byte{0x01, 0x02, 0x03}},
+	}
+
+	for _, b := range []struct {
+		id   uint16
+		body Body
+	}{
+		{MessageIdSetParams, &SetParams{Params: params}},
+		{MessageIdQueryParams, &QueryParams{}},
+		{MessageIdQuerySpecificParams, &QuerySpecificParams{Ids: []uint32{ParamIdHeartbeatInterval, 0xf001}}},
+		{MessageIdParamsResponse, &ParamsResponse{SerialNum: 3, Params: params}},
+	} {
+		data, err := c.Encode(&Message{
+			H: &Header{MessageId: b.id, Phone: 19161017001},
+			B: b.body,
+		})
+		assert.Equal(t, nil, err)
+
+		msg, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, b.body, msg.B)
+	}
+}
+
+func TestParamsResponseCodec_Decode(t *testing.T) {
+	// heartbeat interval 30, an odd length fence radius and a vendor param
+	data, _ := hex.DecodeString("0007" + "03" +
+		"00000001" + "04" + "0000001e" +
+		"00000031" + "01" + "05" +
+		"0000f001" + "02" + "abcd")
+
+	b, err := (&paramsResponseCodec{}).Decode(data)
+	assert.Equal(t, nil, err)
+
+	r := b.(*ParamsResponse)
+	assert.Equal(t, uint16(7), r.SerialNum)
+	assert.Equal(t, uint32(30), r.Get(ParamIdHeartbeatInterval).Value)
+	assert.Equal(t, []byte{0x05}, r.Get(ParamIdFenceRadius).Value)
+	assert.Equal(t, []byte{0xab, 0xcd}, r.Get(0xf001).Value)
+	assert.Equal(t, "0xf001", r.Get(0xf001).Name())
+
+	_, err = (&paramsResponseCodec{}).Decode(data[:len(data)-1])
+	assert.NotEqual(t, nil, err)
+}
+
+func TestParamsResponseCodec_DecodeInvalidGBK(t *testing.T) {
+	// a main server address that is not GBK between two valid params
+	data, _ := hex.DecodeString("0007" + "03" +
+		"00000001" + "04" + "0000001e" +
+		"00000013" + "03" + "ff8120" +
+		"00000083" + "06" + "d4c142313233")
+
+	b, err := (&paramsResponseCodec{}).Decode(data)
+	assert.Equal(t, nil, err)
+
+	r := b.(*ParamsResponse)
+	assert.Equal(t, uint32(30), r.Get(ParamIdHeartbeatInterval).Value)
+	assert.Equal(t, []byte{0xff, 0x81, 0x20}, r.Get(ParamIdMainServerAddress).Value)
+	assert.Equal(t, "粤B123", r.Get(ParamIdPlateNumber).Value)
+
+	// and encoded back as they were
+	encoded, err := (&paramsResponseCodec{}).Encode(r)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, data, encoded)
+}
+
+func TestSetParamsCodec_ParamType(t *testing.T) {
+	_, err := (&setParamsCodec{}).Encode(&SetParams{Params: []*Param{
+		{Id: ParamIdHeartbeatInterval, Value: 30},
+	}})
+	assert.Equal(t, ErrParamType, err)
+
+	_, err = (&paramsResponseCodec{}).Encode(&ParamsResponse{Params: []*Param{
+		{Id: ParamIdHeartbeatInterval, Value: uint32(30)},
+		nil,
+	}})
+	assert.Equal(t, ErrNilParam, err)
+}
@@ -0,0 +1,299 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+var (
+	ErrParamType = errors.New("parameter value does not match its id's type")
+	ErrNilParam  = errors.New("parameter is nil")
+)
+
+// ParamType is how a terminal parameter value is encoded
+type ParamType uint8
+
+const (
+	// raw bytes, the type of unknown and vendor ids
+	ParamBytes ParamType = iota
+	ParamByte
+	ParamWord
+	ParamDword
+	// GBK
+	ParamString
+)
+
+// terminal parameter ids
+const (
+	ParamIdHeartbeatInterval          uint32 = 0x0001
+	ParamIdTCPResponseTimeout         uint32 = 0x0002
+	ParamIdTCPRetransmissions         uint32 = 0x0003
+	ParamIdUDPResponseTimeout         uint32 = 0x0004
+	ParamIdUDPRetransmissions         uint32 = 0x0005
+	ParamIdSMSResponseTimeout         uint32 = 0x0006
+	ParamIdSMSRetransmissions         uint32 = 0x0007
+	ParamIdMainServerAPN              uint32 = 0x0010
+	ParamIdMainServerUser             uint32 = 0x0011
+	ParamIdMainServerPassword         uint32 = 0x0012
+	ParamIdMainServerAddress          uint32 = 0x0013
+	ParamIdBackupServerAPN            uint32 = 0x0014
+	ParamIdBackupServerUser           uint32 = 0x0015
+	ParamIdBackupServerPassword       uint32 = 0x0016
+	ParamIdBackupServerAddress        uint32 = 0x0017
+	ParamIdServerTCPPort              uint32 = 0x0018
+	ParamIdServerUDPPort              uint32 = 0x0019
+	ParamIdICAuthServerAddress        uint32 = 0x001a
+	ParamIdICAuthServerTCPPort        uint32 = 0x001b
+	ParamIdICAuthServerUDPPort        uint32 = 0x001c
+	ParamIdICAuthBackupServerAddress  uint32 = 0x001d
+	ParamIdReportStrategy             uint32 = 0x0020
+	ParamIdReportScheme               uint32 = 0x0021
+	ParamIdDriverAbsentReportInterval uint32 = 0x0022
+	ParamIdSlaveServerAPN             uint32 = 0x0023
+	ParamIdSlaveServerUser            uint32 = 0x0024
+	ParamIdSlaveServerPassword        uint32 = 0x0025
+	ParamIdSlaveServerAddress         uint32 = 0x0026
+	ParamIdSleepReportInterval        uint32 = 0x0027
+	ParamIdEmergencyReportInterval    uint32 = 0x0028
+	ParamIdDefaultReportInterval      uint32 = 0x0029
+	ParamIdDefaultReportDistance      uint32 = 0x002c
+	ParamIdDriverAbsentReportDistance uint32 = 0x002d
+	ParamIdSleepReportDistance        uint32 = 0x002e
+	ParamIdEmergencyReportDistance    uint32 = 0x002f
+	ParamIdTurnAngle                  uint32 = 0x0030
+	ParamIdFenceRadius                uint32 = 0x0031
+	ParamIdMonitorPlatformPhone       uint32 = 0x0040
+	ParamIdResetPhone                 uint32 = 0x0041
+	ParamIdFactoryResetPhone          uint32 = 0x0042
+	ParamIdPlatformSMSPhone           uint32 = 0x0043
+	ParamIdAlarmSMSPhone              uint32 = 0x0044
+	ParamIdAnswerStrategy             uint32 = 0x0045
+	ParamIdMaxCallTime                uint32 = 0x0046
+	ParamIdMaxMonthlyCallTime         uint32 = 0x0047
+	ParamIdListenPhone                uint32 = 0x0048
+	ParamIdPrivilegedSMSPhone         uint32 = 0x0049
+	ParamIdAlarmMask                  uint32 = 0x0050
+	ParamIdAlarmSMSSwitch             uint32 = 0x0051
+	ParamIdAlarmCaptureSwitch         uint32 = 0x0052
+	ParamIdAlarmCaptureStorage        uint32 = 0x0053
+	ParamIdKeyAlarms                  uint32 = 0x0054
+	ParamIdMaxSpeed                   uint32 = 0x0055
+	ParamIdOverspeedDuration          uint32 = 0x0056
+	ParamIdContinuousDrivingLimit     uint32 = 0x0057
+	ParamIdDailyDrivingLimit          uint32 = 0x0058
+	ParamIdMinRestTime                uint32 = 0x0059
+	ParamIdMaxParkingTime             uint32 = 0x005a
+	ParamIdOverspeedWarningDifference uint32 = 0x005b
+	ParamIdFatigueWarningDifference   uint32 = 0x005c
+	ParamIdCollisionAlarm             uint32 = 0x005d
+	ParamIdRolloverAngle              uint32 = 0x005e
+	ParamIdTimedCapture               uint32 = 0x0064
+	ParamIdDistanceCapture            uint32 = 0x0065
+	ParamIdImageQuality               uint32 = 0x0070
+	ParamIdBrightness                 uint32 = 0x0071
+	ParamIdContrast                   uint32 = 0x0072
+	ParamIdSaturation                 uint32 = 0x0073
+	ParamIdChroma                     uint32 = 0x0074
+	ParamIdOdometer                   uint32 = 0x0080
+	ParamIdProvinceId                 uint32 = 0x0081
+	ParamIdCityId                     uint32 = 0x0082
+	ParamIdPlateNumber                uint32 = 0x0083
+	ParamIdPlateColor                 uint32 = 0x0084
+	ParamIdGNSSMode                   uint32 = 0x0090
+	ParamIdGNSSBaudRate               uint32 = 0x0091
+	ParamIdGNSSOutputFrequency        uint32 = 0x0092
+	ParamIdGNSSSamplingFrequency      uint32 = 0x0093
+	ParamIdGNSSUploadMode             uint32 = 0x0094
+	ParamIdGNSSUploadSetting          uint32 = 0x0095
+	ParamIdCAN1SamplingInterval       uint32 = 0x0100
+	ParamIdCAN1UploadInterval         uint32 = 0x0101
+	ParamIdCAN2SamplingInterval       uint32 = 0x0102
+	ParamIdCAN2UploadInterval         uint32 = 0x0103
+)
+
+type paramSpec struct {
+	name string
+	typ  ParamType
+}
+
+// paramSpecs are the parameters of the spec, intervals are in seconds,
+// distances in meters and speeds in km/h
+var paramSpecs = map[uint32]paramSpec{
+	ParamIdHeartbeatInterval:          {"heartbeat_interval", ParamDword},
+	ParamIdTCPResponseTimeout:         {"tcp_response_timeout", ParamDword},
+	ParamIdTCPRetransmissions:         {"tcp_retransmissions", ParamDword},
+	ParamIdUDPResponseTimeout:         {"udp_response_timeout", ParamDword},
+	ParamIdUDPRetransmissions:         {"udp_retransmissions", ParamDword},
+	ParamIdSMSResponseTimeout:         {"sms_response_timeout", ParamDword},
+	ParamIdSMSRetransmissions:         {"sms_retransmissions", ParamDword},
+	ParamIdMainServerAPN:              {"main_server_apn", ParamString},
+	ParamIdMainServerUser:             {"main_server_user", ParamString},
+	ParamIdMainServerPassword:         {"main_server_password", ParamString},
+	ParamIdMainServerAddress:          {"main_server_address", ParamString},
+	ParamIdBackupServerAPN:            {"backup_server_apn", ParamString},
+	ParamIdBackupServerUser:           {"backup_server_user", ParamString},
+	ParamIdBackupServerPassword:       {"backup_server_password", ParamString},
+	ParamIdBackupServerAddress:        {"backup_server_address", ParamString},
+	ParamIdServerTCPPort:              {"server_tcp_port", ParamDword},
+	ParamIdServerUDPPort:              {"server_udp_port", ParamDword},
+	ParamIdICAuthServerAddress:        {"ic_auth_server_address", ParamString},
+	ParamIdICAuthServerTCPPort:        {"ic_auth_server_tcp_port", ParamDword},
+	ParamIdICAuthServerUDPPort:        {"ic_auth_server_udp_port", ParamDword},
+	ParamIdICAuthBackupServerAddress:  {"ic_auth_backup_server_address", ParamString},
+	ParamIdReportStrategy:             {"report_strategy", ParamDword},
+	ParamIdReportScheme:               {"report_scheme", ParamDword},
+	ParamIdDriverAbsentReportInterval: {"driver_absent_report_interval", ParamDword},
+	ParamIdSlaveServerAPN:             {"slave_server_apn", ParamString},
+	ParamIdSlaveServerUser:            {"slave_server_user", ParamString},
+	ParamIdSlaveServerPassword:        {"slave_server_password", ParamString},
+	ParamIdSlaveServerAddress:         {"slave_server_address", ParamString},
+	ParamIdSleepReportInterval:        {"sleep_report_interval", ParamDword},
+	ParamIdEmergencyReportInterval:    {"emergency_report_interval", ParamDword},
+	ParamIdDefaultReportInterval:      {"default_report_interval", ParamDword},
+	ParamIdDefaultReportDistance:      {"default_report_distance", ParamDword},
+	ParamIdDriverAbsentReportDistance: {"driver_absent_report_distance", ParamDword},
+	ParamIdSleepReportDistance:        {"sleep_report_distance", ParamDword},
+	ParamIdEmergencyReportDistance:    {"emergency_report_distance", ParamDword},
+	ParamIdTurnAngle:                  {"turn_angle", ParamDword},
+	ParamIdFenceRadius:                {"fence_radius", ParamWord},
+	ParamIdMonitorPlatformPhone:       {"monitor_platform_phone", ParamString},
+	ParamIdResetPhone:                 {"reset_phone", ParamString},
+	ParamIdFactoryResetPhone:          {"factory_reset_phone", ParamString},
+	ParamIdPlatformSMSPhone:           {"platform_sms_phone", ParamString},
+	ParamIdAlarmSMSPhone:              {"alarm_sms_phone", ParamString},
+	ParamIdAnswerStrategy:             {"answer_strategy", ParamDword},
+	ParamIdMaxCallTime:                {"max_call_time", ParamDword},
+	ParamIdMaxMonthlyCallTime:         {"max_monthly_call_time", ParamDword},
+	ParamIdListenPhone:                {"listen_phone", ParamString},
+	ParamIdPrivilegedSMSPhone:         {"privileged_sms_phone", ParamString},
+	ParamIdAlarmMask:                  {"alarm_mask", ParamDword},
+	ParamIdAlarmSMSSwitch:             {"alarm_sms_switch", ParamDword},
+	ParamIdAlarmCaptureSwitch:         {"alarm_capture_switch", ParamDword},
+	ParamIdAlarmCaptureStorage:        {"alarm_capture_storage", ParamDword},
+	ParamIdKeyAlarms:                  {"key_alarms", ParamDword},
+	ParamIdMaxSpeed:                   {"max_speed", ParamDword},
+	ParamIdOverspeedDuration:          {"overspeed_duration", ParamDword},
+	ParamIdContinuousDrivingLimit:     {"continuous_driving_limit", ParamDword},
+	ParamIdDailyDrivingLimit:          {"daily_driving_limit", ParamDword},
+	ParamIdMinRestTime:                {"min_rest_time", ParamDword},
+	ParamIdMaxParkingTime:             {"max_parking_time", ParamDword},
+	ParamIdOverspeedWarningDifference: {"overspeed_warning_difference", ParamWord},
+	ParamIdFatigueWarningDifference:   {"fatigue_warning_difference", ParamWord},
+	ParamIdCollisionAlarm:             {"collision_alarm", ParamWord},
+	ParamIdRolloverAngle:              {"rollover_angle", ParamWord},
+	ParamIdTimedCapture:               {"timed_capture", ParamDword},
+	ParamIdDistanceCapture:            {"distance_capture", ParamDword},
+	ParamIdImageQuality:               {"image_quality", ParamDword},
+	ParamIdBrightness:                 {"brightness", ParamDword},
+	ParamIdContrast:                   {"contrast", ParamDword},
+	ParamIdSaturation:                 {"saturation", ParamDword},
+	ParamIdChroma:                     {"chroma", ParamDword},
+	ParamIdOdometer:                   {"odometer", ParamDword},
+	ParamIdProvinceId:                 {"province_id", ParamWord},
+	ParamIdCityId:                     {"city_id", ParamWord},
+	ParamIdPlateNumber:                {"plate_number", ParamString},
+	ParamIdPlateColor:                 {"plate_color", ParamByte},
+	ParamIdGNSSMode:                   {"gnss_mode", ParamByte},
+	ParamIdGNSSBaudRate:               {"gnss_baud_rate", ParamByte},
+	ParamIdGNSSOutputFrequency:        {"gnss_output_frequency", ParamByte},
+	ParamIdGNSSSamplingFrequency:      {"gnss_sampling_frequency", ParamDword},
+	ParamIdGNSSUploadMode:             {"gnss_upload_mode", ParamByte},
+	ParamIdGNSSUploadSetting:          {"gnss_upload_setting", ParamDword},
+	ParamIdCAN1SamplingInterval:       {"can1_sampling_interval", ParamDword},
+	ParamIdCAN1UploadInterval:         {"can1_upload_interval", ParamWord},
+	ParamIdCAN2SamplingInterval:       {"can2_sampling_interval", ParamDword},
+	ParamIdCAN2UploadInterval:         {"can2_upload_interval", ParamWord},
+}
+
+// ParamTypeOf returns the type of parameter id, ParamBytes if the id is not
+// in the spec
+func ParamTypeOf(id uint32) ParamType {
+	return paramSpecs[id].typ
+}
+
+// Param is a terminal parameter. Value is a uint32, uint16, uint8 or string
+// for DWORD, WORD, BYTE and STRING parameters. It is a []byte for ids not in
+// the spec, e.g. vendor ones, and for known ids a terminal sent with a
+// length other than their type's, any id may be set with a []byte.
+type Param struct {
+	Id    uint32
+	Value interface{}
+}
+
+// Name returns the snake_case name of the parameter, or its hex id
+func (p *Param) Name() string {
+	if spec, ok := paramSpecs[p.Id]; ok {
+		return spec.name
+	}
+	return fmt.Sprintf("0x%04x", p.Id)
+}
+
+func (p *Param) Human() string {
+	if b, ok := p.Value.([]byte); ok {
+		return fmt.Sprintf("%s: %s\n", p.Name(), hex.EncodeToString(b))
+	}
+	return fmt.Sprintf("%s: %v\n", p.Name(), p.Value)
+}
+
+func (p *Param) encodeValue() ([]byte, error) {
+	var typ = ParamTypeOf(p.Id)
+
+	var res []byte
+	switch v := p.Value.(type) {
+	case []byte:
+		return v, nil
+	case uint32:
+		if typ != ParamDword {
+			return nil, ErrParamType
+		}
+		res = make([]byte, 4)
+		binary.BigEndian.PutUint32(res, v)
+	case uint16:
+		if typ != ParamWord {
+			return nil, ErrParamType
+		}
+		res = make([]byte, 2)
+		binary.BigEndian.PutUint16(res, v)
+	case uint8:
+		if typ != ParamByte {
+			return nil, ErrParamType
+		}
+		res = []byte{v}
+	case string:
+		if typ != ParamString {
+			return nil, ErrParamType
+		}
+		return utils.EncodeGBK(v)
+	default:
+		return nil, ErrParamType
+	}
+	return res, nil
+}
+
+func decodeParamValue(id uint32, data []byte) (interface{}, error) {
+	switch ParamTypeOf(id) {
+	case ParamDword:
+		if len(data) == 4 {
+			return binary.BigEndian.Uint32(data), nil
+		}
+	case ParamWord:
+		if len(data) == 2 {
+			return binary.BigEndian.Uint16(data), nil
+		}
+	case ParamByte:
+		if len(data) == 1 {
+			return data[0], nil
+		}
+	case ParamString:
+		// values that are not GBK are kept raw so one bad parameter does
+		// not fail the others
+		if s, err := utils.DecodeGBK(data); err == nil {
+			return s, nil
+		}
+	}
+	return data, nil
+}
@@ -2,19 +2,57 @@ package codec
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 )
 
 const (
 	MessageHeaderMaxLength    = 16
 	MessageHeaderNormalLength = 12
+
+	MessageHeaderMaxLength2019    = 21
+	MessageHeaderNormalLength2019 = 17
+
+	// body length is 10 bits of the message attributes
+	MaxBodyLength = 0x03ff
+)
+
+// protocol versions
+const (
+	// detect the version by the version flag of the message attributes
+	VersionAuto = ""
+	Version2013 = "2013"
+	Version2019 = "2019"
+)
+
+// message ids
+const (
+	MessageIdTerminalResponse uint16 = 0x0001
+	MessageIdHeartbeat        uint16 = 0x0002
+	// 2019 only
+	MessageIdTerminalRetransmissionRequest uint16 = 0x0005
+	MessageIdTerminalRegister              uint16 = 0x0100
+	MessageIdTerminalAuth                  uint16 = 0x0102
+	MessageIdParamsResponse                uint16 = 0x0104
+	MessageIdLocationReport                uint16 = 0x0200
+	MessageIdLocationQueryResponse         uint16 = 0x0201
+	MessageIdBatchLocationReport           uint16 = 0x0704
+	MessageIdServerResponse                uint16 = 0x8001
+	MessageIdRetransmissionRequest         uint16 = 0x8003
+	MessageIdRegisterResponse              uint16 = 0x8100
+	MessageIdSetParams                     uint16 = 0x8103
+	MessageIdQueryParams                   uint16 = 0x8104
+	MessageIdTerminalControl               uint16 = 0x8105
+	MessageIdQuerySpecificParams           uint16 = 0x8106
+	MessageIdLocationQuery                 uint16 = 0x8201
+	MessageIdTemporaryTracking             uint16 = 0x8202
 )
 
 type BodyAttr struct {
-	SegmentationEnabled bool
-	Preserved           uint8
-	EncryptionMethod    string
-	BodyLength          uint16
+	SegmentationEnabled bool   `json:"segmentation_enabled"`
+	Preserved           uint8  `json:"preserved"`
+	EncryptionMethod    string `json:"encryption_method"`
+	BodyLength          uint16 `json:"body_length"`
 }
 
 func (b *BodyAttr) Human() string {
@@ -29,16 +67,38 @@ func (b *BodyAttr) Human() string {
 }
 
 type SegmentInfo struct {
-	TotalSegments uint16
-	SegmentNum    uint16
+	TotalSegments uint16 `json:"total_segments"`
+	SegmentNum    uint16 `json:"segment_num"`
 }
 
 type Header struct {
-	MessageId uint16
-	Attr      *BodyAttr
-	Phone     uint64
-	SerialNum uint16
-	SegInfo   *SegmentInfo
+	MessageId uint16    `json:"message_id"`
+	Attr      *BodyAttr `json:"attr"`
+	// Version2013 or Version2019, VersionAuto encodes with the codec's version
+	Version string `json:"version"`
+	// 2019 only, 1 for the first 2019 revision
+	ProtocolVersion uint8 `json:"protocol_version"`
+	// 12 BCD digits in 2013, 20 in 2019. 2019 phones over
+	// 18446744073709551615 do not fit, decoding them fails with
+	// ErrPhoneOutOfRange.
+	Phone     uint64       `json:"phone"`
+	SerialNum uint16       `json:"serial_num"`
+	SegInfo   *SegmentInfo `json:"seg_info,omitempty"`
+}
+
+// Length returns the encoded header length
+func (h *Header) Length() int {
+	var segmented = h.Attr != nil && h.Attr.SegmentationEnabled
+	if h.Version == Version2019 {
+		if segmented {
+			return MessageHeaderMaxLength2019
+		}
+		return MessageHeaderNormalLength2019
+	}
+	if segmented {
+		return MessageHeaderMaxLength
+	}
+	return MessageHeaderNormalLength
 }
 
 func (h *Header) Human() string {
@@ -46,6 +106,9 @@ func (h *Header) Human() string {
 
 	buf.WriteString(fmt.Sprintf("message id: %d\n", h.MessageId))
 	buf.WriteString(fmt.Sprintf("attribute: %s\n", h.Attr.Human()))
+	if h.Version == Version2019 {
+		buf.WriteString(fmt.Sprintf("protocol version: %d\n", h.ProtocolVersion))
+	}
 	buf.WriteString(fmt.Sprintf("phone: %d\n", h.Phone))
 	buf.WriteString(fmt.Sprintf("serial num: %d\n", h.SerialNum))
 	if h.Attr.SegmentationEnabled {
@@ -60,6 +123,19 @@ type Body interface {
 	Human() string
 }
 
+// RawBody carries the undecoded body bytes of a message with no registered body codec
+type RawBody struct {
+	Data []byte
+}
+
+func (r *RawBody) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("raw: %s\n", hex.EncodeToString(r.Data)))
+
+	return buf.String()
+}
+
 type Message struct {
 	Identifier uint8
 	H          *Header
@@ -77,25 +153,62 @@ func (m *Message) Human() string {
 	return buf.String()
 }
 
+// general response results
+const (
+	ResultSuccess      uint8 = 0
+	ResultFailure      uint8 = 1
+	ResultMessageError uint8 = 2
+	ResultNotSupported uint8 = 3
+	// 0x8001 only
+	ResultAlarmConfirm uint8 = 4
+)
+
+func resultHuman(result uint8) string {
+	switch result {
+	case ResultSuccess:
+		return "success"
+	case ResultFailure:
+		return "failure"
+	case ResultMessageError:
+		return "message error"
+	case ResultNotSupported:
+		return "not supported"
+	case ResultAlarmConfirm:
+		return "alarm confirm"
+	}
+	return fmt.Sprintf("unknown (%d)", result)
+}
+
+// ServerResponse is the platform general response (0x8001) body
 type ServerResponse struct {
-	SerialNum uint16
-	ID        uint16
-	Result    uint8
+	SerialNum uint16 `json:"serial_num"`
+	ID        uint16 `json:"message_id"`
+	Result    uint8  `json:"result"`
 }
 
 func (r *ServerResponse) Human() string {
 	var buf bytes.Buffer
 
-	var res string
-	if r.Result == 1 {
-		res = "failure"
-	} else if r.Result == 0 {
-		res = "success"
-	}
+	buf.WriteString(fmt.Sprintf("serial num: %d\n", r.SerialNum))
+	buf.WriteString(fmt.Sprintf("message id: %d\n", r.ID))
+	buf.WriteString(fmt.Sprintf("result: %s\n", resultHuman(r.Result)))
+
+	return buf.String()
+}
+
+// TerminalResponse is the terminal general response (0x0001) body
+type TerminalResponse struct {
+	SerialNum uint16 `json:"serial_num"`
+	ID        uint16 `json:"message_id"`
+	Result    uint8  `json:"result"`
+}
+
+func (r *TerminalResponse) Human() string {
+	var buf bytes.Buffer
 
 	buf.WriteString(fmt.Sprintf("serial num: %d\n", r.SerialNum))
 	buf.WriteString(fmt.Sprintf("message id: %d\n", r.ID))
-	buf.WriteString(fmt.Sprintf("result: %s\n", res))
+	buf.WriteString(fmt.Sprintf("result: %s\n", resultHuman(r.Result)))
 
 	return buf.String()
 }
@@ -0,0 +1,273 @@
+package codec
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSegmentMismatch    = errors.New("segment does not match its segment set")
+	ErrTooManySegmentSets = errors.New("too many pending segmented messages for the phone")
+	ErrReassemblerFull    = errors.New("reassembler buffer is full")
+)
+
+const (
+	DefaultMaxPendingPerPhone = 16
+	// 16 MiB
+	DefaultMaxBufferedBytes = 16 << 20
+)
+
+// segments of one message share phone, message id and the serial num of the
+// first segment
+type segmentKey struct {
+	phone          uint64
+	messageId      uint16
+	firstSerialNum uint16
+}
+
+type segmentSet struct {
+	header   *Header
+	total    uint16
+	segments map[uint16][]byte
+	// bytes of segments
+	size    int
+	updated time.Time
+}
+
+// IncompleteMessage is a segmented message still waiting for segments
+type IncompleteMessage struct {
+	Header *Header
+	// serial num of the first segment
+	FirstSerialNum uint16
+	TotalSegments  uint16
+	// segment nums start at 1
+	Missing []uint16
+}
+
+// RetransmissionRequest builds the 0x8003 message asking for the missing
+// segments
+func (m *IncompleteMessage) RetransmissionRequest() *Message {
+	return &Message{
+		H: &Header{
+			MessageId:       MessageIdRetransmissionRequest,
+			Attr:            &BodyAttr{},
+			Version:         m.Header.Version,
+			ProtocolVersion: m.Header.ProtocolVersion,
+			Phone:           m.Header.Phone,
+		},
+		B: &RetransmissionRequest{
+			SerialNum:   m.FirstSerialNum,
+			SegmentNums: m.Missing,
+		},
+	}
+}
+
+// Reassembler buffers segments until every segment of a message arrived.
+// It is safe for concurrent use, its exported fields must be set before the
+// first Add.
+type Reassembler struct {
+	// MaxPendingPerPhone limits the incomplete messages of one phone, a
+	// segment starting one more fails with ErrTooManySegmentSets
+	MaxPendingPerPhone int
+	// MaxBufferedBytes limits the bytes of all buffered segments, a segment
+	// exceeding it fails with ErrReassemblerFull
+	MaxBufferedBytes int
+
+	codec   Codec
+	timeout time.Duration
+
+	mu       sync.Mutex
+	sets     map[segmentKey]*segmentSet
+	pending  map[uint64]int
+	buffered int
+
+	// for tests
+	now func() time.Time
+}
+
+// NewReassembler decodes reassembled bodies with c, segment sets without a
+// new segment for timeout are dropped by Expire. Limits are
+// DefaultMaxPendingPerPhone and DefaultMaxBufferedBytes.
+func NewReassembler(c Codec, timeout time.Duration) *Reassembler {
+	return &Reassembler{
+		MaxPendingPerPhone: DefaultMaxPendingPerPhone,
+		MaxBufferedBytes:   DefaultMaxBufferedBytes,
+		codec:              c,
+		timeout:            timeout,
+		sets:               make(map[segmentKey]*segmentSet),
+		pending:            make(map[uint64]int),
+		now:                time.Now,
+	}
+}
+
+// Add returns unsegmented messages as they are. A segment is buffered and nil
+// is returned, until the last missing segment of its message arrives and the
+// reassembled message is returned. Segments are copied, msg may be reused,
+// e.g. by DecodeInto, once Add returns.
+func (r *Reassembler) Add(msg *Message) (*Message, error) {
+	if msg.H.Attr == nil || !msg.H.Attr.SegmentationEnabled || msg.H.SegInfo == nil {
+		return msg, nil
+	}
+
+	raw, ok := msg.B.(*RawBody)
+	if !ok {
+		return nil, ErrSegmentMismatch
+	}
+
+	seg := msg.H.SegInfo
+	if seg.SegmentNum < 1 || seg.SegmentNum > seg.TotalSegments {
+		return nil, ErrSegmentMismatch
+	}
+
+	key := segmentKey{
+		phone:          msg.H.Phone,
+		messageId:      msg.H.MessageId,
+		firstSerialNum: msg.H.SerialNum - (seg.SegmentNum - 1),
+	}
+
+	r.mu.Lock()
+	set, ok := r.sets[key]
+	if ok && set.total != seg.TotalSegments {
+		r.mu.Unlock()
+		return nil, ErrSegmentMismatch
+	}
+	if !ok && r.pending[key.phone] >= r.MaxPendingPerPhone {
+		r.mu.Unlock()
+		return nil, ErrTooManySegmentSets
+	}
+	// a segment sent again replaces the previous one
+	var grow = len(raw.Data)
+	if ok {
+		grow -= len(set.segments[seg.SegmentNum])
+	}
+	if r.buffered+grow > r.MaxBufferedBytes {
+		r.mu.Unlock()
+		return nil, ErrReassemblerFull
+	}
+
+	if !ok {
+		set = &segmentSet{
+			total:    seg.TotalSegments,
+			segments: make(map[uint16][]byte),
+		}
+		r.sets[key] = set
+		r.pending[key.phone]++
+	}
+	if set.header == nil || seg.SegmentNum == 1 {
+		set.header = copyHeader(msg.H)
+	}
+	set.segments[seg.SegmentNum] = append([]byte(nil), raw.Data...)
+	set.size += grow
+	r.buffered += grow
+	set.updated = r.now()
+
+	if len(set.segments) < int(set.total) {
+		r.mu.Unlock()
+		return nil, nil
+	}
+	r.remove(key, set)
+	r.mu.Unlock()
+
+	var body []byte
+	// int, a uint16 would wrap at 65535 segments
+	for i := 1; i <= int(set.total); i++ {
+		body = append(body, set.segments[uint16(i)]...)
+	}
+
+	var header = *set.header
+	var attr = *header.Attr
+	attr.SegmentationEnabled = false
+	attr.BodyLength = uint16(len(body))
+	header.Attr = &attr
+	header.SegInfo = nil
+	header.SerialNum = key.firstSerialNum
+
+	b, err := r.codec.DecodeBody(&header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{H: &header, B: b}, nil
+}
+
+func copyHeader(h *Header) *Header {
+	var c = *h
+	if h.Attr != nil {
+		var attr = *h.Attr
+		c.Attr = &attr
+	}
+	if h.SegInfo != nil {
+		var seg = *h.SegInfo
+		c.SegInfo = &seg
+	}
+	return &c
+}
+
+func (s *segmentSet) incomplete(key segmentKey) *IncompleteMessage {
+	var m = &IncompleteMessage{
+		Header:         s.header,
+		FirstSerialNum: key.firstSerialNum,
+		TotalSegments:  s.total,
+	}
+	for i := 1; i <= int(s.total); i++ {
+		if _, ok := s.segments[uint16(i)]; !ok {
+			m.Missing = append(m.Missing, uint16(i))
+		}
+	}
+	return m
+}
+
+// Missing returns the messages still waiting for segments
+func (r *Reassembler) Missing() []*IncompleteMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []*IncompleteMessage
+	for key, set := range r.sets {
+		res = append(res, set.incomplete(key))
+	}
+	sortIncomplete(res)
+	return res
+}
+
+// Expire drops and returns the messages that got no segment within the
+// timeout, it should be called periodically.
+func (r *Reassembler) Expire() []*IncompleteMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []*IncompleteMessage
+	var now = r.now()
+	for key, set := range r.sets {
+		if now.Sub(set.updated) < r.timeout {
+			continue
+		}
+		res = append(res, set.incomplete(key))
+		r.remove(key, set)
+	}
+	sortIncomplete(res)
+	return res
+}
+
+// remove must be called with r.mu held
+func (r *Reassembler) remove(key segmentKey, set *segmentSet) {
+	delete(r.sets, key)
+	r.buffered -= set.size
+	if r.pending[key.phone]--; r.pending[key.phone] <= 0 {
+		delete(r.pending, key.phone)
+	}
+}
+
+func sortIncomplete(ms []*IncompleteMessage) {
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Header.Phone != ms[j].Header.Phone {
+			return ms[i].Header.Phone < ms[j].Header.Phone
+		}
+		if ms[i].Header.MessageId != ms[j].Header.MessageId {
+			return ms[i].Header.MessageId < ms[j].Header.MessageId
+		}
+		return ms[i].FirstSerialNum < ms[j].FirstSerialNum
+	})
+}
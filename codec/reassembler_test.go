@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReassembler(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	sample, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	sample.H.SerialNum = 0xfffe
+
+	frames, err := c.EncodeSegmented(sample, 100)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, len(frames))
+
+	var now = time.Unix(1476671276, 0)
+	r := NewReassembler(c, time.Minute)
+	r.now = func() time.Time { return now }
+
+	for _, i := range []int{3, 0, 1} {
+		msg, err := c.Decode(frames[i])
+		assert.Equal(t, nil, err)
+		msg, err = r.Add(msg)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, (*Message)(nil), msg)
+	}
+
+	missing := r.Missing()
+	assert.Equal(t, 1, len(missing))
+	assert.Equal(t, uint16(0xfffe), missing[0].FirstSerialNum)
+	assert.Equal(t, []uint16{3}, missing[0].Missing)
+
+	req, err := c.Encode(missing[0].RetransmissionRequest())
+	assert.Equal(t, nil, err)
+	decodedReq, err := c.Decode(req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &RetransmissionRequest{SerialNum: 0xfffe, SegmentNums: []uint16{3}}, decodedReq.B)
+
+	msg, _ := c.Decode(frames[2])
+	msg, err = r.Add(msg)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint16(0xfffe), msg.H.SerialNum)
+	assert.Equal(t, false, msg.H.Attr.SegmentationEnabled)
+	assert.Equal(t, sample.B, msg.B)
+	assert.Equal(t, 0, len(r.Missing()))
+
+	// incomplete sets expire
+	msg, _ = c.Decode(frames[0])
+	r.Add(msg)
+	assert.Equal(t, 0, len(r.Expire()))
+	now = now.Add(time.Minute)
+	expired := r.Expire()
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, []uint16{2, 3, 4}, expired[0].Missing)
+	assert.Equal(t, 0, len(r.Missing()))
+
+	// unsegmented messages pass through
+	msg, err = r.Add(sample)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sample, msg)
+}
+
+// segments decoded into one buffer and message, as a connection reading
+// with DecodeInto would
+func TestReassembler_DecodeInto(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, _ := hex.DecodeString(sampleLocationFrame)
+	sample, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	sample.H.SerialNum = 10
+
+	frames, err := c.EncodeSegmented(sample, 100)
+	assert.Equal(t, nil, err)
+
+	r := NewReassembler(c, time.Minute)
+	var buf = make([]byte, 1024)
+	var msg Message
+	var reassembled *Message
+	for _, frame := range frames {
+		n := copy(buf, frame)
+		assert.Equal(t, nil, c.DecodeInto(&msg, buf[:n]))
+		reassembled, err = r.Add(&msg)
+		assert.Equal(t, nil, err)
+	}
+
+	assert.NotEqual(t, (*Message)(nil), reassembled)
+	assert.Equal(t, uint16(10), reassembled.H.SerialNum)
+	assert.Equal(t, sample.H.Phone, reassembled.H.Phone)
+	assert.Equal(t, sample.B, reassembled.B)
+}
+
+func TestCodec_EncodeSegmented(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var body = make([]byte, MaxBodyLength*2+1)
+	for i := range body {
+		body[i] = byte(i)
+	}
+	var msg = &Message{
+		H: &Header{MessageId: 0x8108, Phone: 19161017001, SerialNum: 10},
+		B: &RawBody{Data: body},
+	}
+
+	_, err := c.Encode(msg)
+	assert.Equal(t, ErrBodyTooLong, err)
+
+	frames, err := c.EncodeSegmented(msg, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(frames))
+
+	var joined []byte
+	for i, frame := range frames {
+		decoded, err := c.Decode(frame)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, decoded.H.Attr.SegmentationEnabled)
+		assert.Equal(t, uint16(10+i), decoded.H.SerialNum)
+		assert.Equal(t, &SegmentInfo{TotalSegments: 3, SegmentNum: uint16(i + 1)}, decoded.H.SegInfo)
+		joined = append(joined, decoded.B.(*RawBody).Data...)
+	}
+	assert.Equal(t, body, joined)
+
+	// short bodies stay in one unsegmented frame
+	frames, err = c.EncodeSegmented(&Message{H: msg.H, B: &RawBody{Data: body[:10]}}, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(frames))
+	decoded, _ := c.Decode(frames[0])
+	assert.Equal(t, false, decoded.H.Attr.SegmentationEnabled)
+}
+
+func TestReassembler_Limits(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	segment := func(phone uint64, serialNum uint16, size int) *Message {
+		return &Message{
+			H: &Header{
+				MessageId: MessageIdLocationReport,
+				Attr:      &BodyAttr{SegmentationEnabled: true},
+				Phone:     phone,
+				SerialNum: serialNum,
+				SegInfo:   &SegmentInfo{TotalSegments: 0xffff, SegmentNum: 1},
+			},
+			B: &RawBody{Data: make([]byte, size)},
+		}
+	}
+
+	r := NewReassembler(c, time.Minute)
+	r.MaxPendingPerPhone = 2
+	r.MaxBufferedBytes = 2500
+
+	// first segments with ever changing serial nums
+	for i := uint16(0); i < 2; i++ {
+		_, err := r.Add(segment(1, i, 1000))
+		assert.Equal(t, nil, err)
+	}
+	_, err := r.Add(segment(1, 2, 1000))
+	assert.Equal(t, ErrTooManySegmentSets, err)
+
+	_, err = r.Add(segment(2, 0, 1000))
+	assert.Equal(t, ErrReassemblerFull, err)
+	_, err = r.Add(segment(2, 0, 500))
+	assert.Equal(t, nil, err)
+	// sent again, replacing the buffered one
+	_, err = r.Add(segment(2, 0, 500))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(r.Missing()))
+
+	// expired sets free their room
+	r.now = func() time.Time { return time.Now().Add(time.Minute) }
+	assert.Equal(t, 3, len(r.Expire()))
+	_, err = r.Add(segment(1, 2, 1000))
+	assert.Equal(t, nil, err)
+	_, err = r.Add(segment(2, 0, 1000))
+	assert.Equal(t, nil, err)
+}
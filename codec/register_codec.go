@@ -0,0 +1,241 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+var (
+	ErrBodyNotRegister         = errors.New("body is not terminal register")
+	ErrBodyNotRegisterResponse = errors.New("body is not register response")
+)
+
+const (
+	ManufacturerIdLength = 5
+	TerminalModelLength  = 20
+	TerminalIdLength     = 7
+
+	ManufacturerIdLength2019 = 11
+	TerminalModelLength2019  = 30
+	TerminalIdLength2019     = 30
+)
+
+// registerFieldLengths returns the manufacturer id, terminal model and
+// terminal id lengths of version
+func registerFieldLengths(version string) (int, int, int) {
+	if version == Version2019 {
+		return ManufacturerIdLength2019, TerminalModelLength2019, TerminalIdLength2019
+	}
+	return ManufacturerIdLength, TerminalModelLength, TerminalIdLength
+}
+
+// register results of 0x8100
+const (
+	RegisterResultSuccess            uint8 = 0
+	RegisterResultVehicleRegistered  uint8 = 1
+	RegisterResultVehicleNotFound    uint8 = 2
+	RegisterResultTerminalRegistered uint8 = 3
+	RegisterResultTerminalNotFound   uint8 = 4
+)
+
+// RegisterMsgBody is the terminal register (0x0100) body
+type RegisterMsgBody struct {
+	ProvinceId     uint16 `json:"province_id"`
+	CityId         uint16 `json:"city_id"`
+	ManufacturerId string `json:"manufacturer_id"`
+	TerminalModel  string `json:"terminal_model"`
+	TerminalId     string `json:"terminal_id"`
+	// 0 if the vehicle has no plate, PlateNumber is the VIN then
+	PlateColor  uint8  `json:"plate_color"`
+	PlateNumber string `json:"plate_number"`
+}
+
+func (r *RegisterMsgBody) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("province id: %d\n", r.ProvinceId))
+	buf.WriteString(fmt.Sprintf("city id: %d\n", r.CityId))
+	buf.WriteString(fmt.Sprintf("manufacturer id: %s\n", r.ManufacturerId))
+	buf.WriteString(fmt.Sprintf("terminal model: %s\n", r.TerminalModel))
+	buf.WriteString(fmt.Sprintf("terminal id: %s\n", r.TerminalId))
+	buf.WriteString(fmt.Sprintf("plate color: %d\n", r.PlateColor))
+	buf.WriteString(fmt.Sprintf("plate number: %s\n", r.PlateNumber))
+
+	return buf.String()
+}
+
+type registerCodec struct {
+}
+
+// 0x0100
+func (c *registerCodec) Encode(b Body) ([]byte, error) {
+	return c.EncodeVersion(b, Version2013)
+}
+
+func (c *registerCodec) Decode(data []byte) (Body, error) {
+	return c.DecodeVersion(data, Version2013)
+}
+
+func (c *registerCodec) EncodeVersion(b Body, version string) ([]byte, error) {
+	r, ok := b.(*RegisterMsgBody)
+	if !ok {
+		return nil, ErrBodyNotRegister
+	}
+
+	manufacturerIdLength, modelLength, terminalIdLength := registerFieldLengths(version)
+
+	var res = make([]byte, 0, 5+manufacturerIdLength+modelLength+terminalIdLength+len(r.PlateNumber))
+	res = appendWord(res, r.ProvinceId)
+	res = appendWord(res, r.CityId)
+
+	var err error
+	for _, f := range []struct {
+		s string
+		n int
+	}{
+		{r.ManufacturerId, manufacturerIdLength},
+		{r.TerminalModel, modelLength},
+		{r.TerminalId, terminalIdLength},
+	} {
+		res, err = appendFixedString(res, f.s, f.n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res = append(res, r.PlateColor)
+
+	plate, err := utils.EncodeGBK(r.PlateNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(res, plate...), nil
+}
+
+func (c *registerCodec) DecodeVersion(data []byte, version string) (Body, error) {
+	var r RegisterMsgBody
+
+	manufacturerIdLength, modelLength, terminalIdLength := registerFieldLengths(version)
+
+	br := newBodyReader(data)
+	r.ProvinceId = br.word()
+	r.CityId = br.word()
+	manufacturerId := br.bytes(manufacturerIdLength)
+	model := br.bytes(modelLength)
+	terminalId := br.bytes(terminalIdLength)
+	r.PlateColor = br.byte()
+	plate := br.rest()
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for _, f := range []struct {
+		bs []byte
+		s  *string
+	}{
+		{manufacturerId, &r.ManufacturerId},
+		{model, &r.TerminalModel},
+		{terminalId, &r.TerminalId},
+	} {
+		*f.s, err = decodeFixedString(f.bs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the plate is the rest of the body, it is not padded
+	r.PlateNumber, err = utils.DecodeGBK(plate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// RegisterResponse is the platform register response (0x8100) body
+type RegisterResponse struct {
+	SerialNum uint16 `json:"serial_num"`
+	Result    uint8  `json:"result"`
+	// only present on success
+	AuthCode string `json:"auth_code,omitempty"`
+}
+
+func (r *RegisterResponse) Human() string {
+	var buf bytes.Buffer
+
+	var res string
+	switch r.Result {
+	case RegisterResultSuccess:
+		res = "success"
+	case RegisterResultVehicleRegistered:
+		res = "vehicle already registered"
+	case RegisterResultVehicleNotFound:
+		res = "vehicle not found"
+	case RegisterResultTerminalRegistered:
+		res = "terminal already registered"
+	case RegisterResultTerminalNotFound:
+		res = "terminal not found"
+	}
+
+	buf.WriteString(fmt.Sprintf("serial num: %d\n", r.SerialNum))
+	buf.WriteString(fmt.Sprintf("result: %s\n", res))
+	if r.Result == RegisterResultSuccess {
+		buf.WriteString(fmt.Sprintf("auth code: %s\n", r.AuthCode))
+	}
+
+	return buf.String()
+}
+
+type registerResponseCodec struct {
+}
+
+// 0x8100
+func (c *registerResponseCodec) Encode(b Body) ([]byte, error) {
+	r, ok := b.(*RegisterResponse)
+	if !ok {
+		return nil, ErrBodyNotRegisterResponse
+	}
+
+	var res = append(appendWord(make([]byte, 0, 3+len(r.AuthCode)), r.SerialNum), r.Result)
+	if r.Result == RegisterResultSuccess {
+		authCode, err := utils.EncodeGBK(r.AuthCode)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, authCode...)
+	}
+
+	return res, nil
+}
+
+func (c *registerResponseCodec) Decode(data []byte) (Body, error) {
+	var r RegisterResponse
+
+	br := newBodyReader(data)
+	r.SerialNum = br.word()
+	r.Result = br.byte()
+	authCode := br.rest()
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	if r.Result != RegisterResultSuccess {
+		// only successes have an auth code
+		if len(authCode) > 0 {
+			return nil, &DecodeError{Offset: 3, Err: ErrTrailingData}
+		}
+		return &r, nil
+	}
+
+	var err error
+	r.AuthCode, err = utils.DecodeGBK(authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
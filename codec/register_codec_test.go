@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/sceneryback/jtt808/utils"
+)
+
+func TestRegisterCodec(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	var body = &RegisterMsgBody{
+		ProvinceId:     44,
+		CityId:         300,
+		ManufacturerId: "70111",
+		TerminalModel:  "JT-808A",
+		TerminalId:     "0000001",
+		PlateColor:     1,
+		PlateNumber:    "粤B12345",
+	}
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdTerminalRegister, Phone: 19161017001},
+		B: body,
+	})
+	assert.Equal(t, nil, err)
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 37+8, int(msg.H.Attr.BodyLength))
+	assert.Equal(t, body, msg.B)
+
+	_, err = c.Encode(&Message{
+		H: &Header{MessageId: MessageIdTerminalRegister},
+		B: &RegisterMsgBody{TerminalId: "00000001"},
+	})
+	assert.Equal(t, ErrFieldTooLong, err)
+
+	// fixed fields lose their padding, the plate keeps its bytes
+	raw := msg.B.(*RegisterMsgBody)
+	data, err = (&registerCodec{}).Encode(raw)
+	assert.Equal(t, nil, err)
+	decoded, err := (&registerCodec{}).Decode(append(data, 0x00))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "粤B12345\x00", decoded.(*RegisterMsgBody).PlateNumber)
+	_, err = (&registerCodec{}).Decode(append(data, 0xff))
+	assert.Equal(t, utils.ErrInvalidGBK, err)
+}
+
+func TestRegisterResponseCodec(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	for _, body := range []*RegisterResponse{
+		{SerialNum: 3, Result: RegisterResultSuccess, AuthCode: "a1b2c3"},
+		{SerialNum: 4, Result: RegisterResultTerminalNotFound},
+	} {
+		data, err := c.Encode(&Message{
+			H: &Header{MessageId: MessageIdRegisterResponse, Phone: 19161017001},
+			B: body,
+		})
+		assert.Equal(t, nil, err)
+
+		msg, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, body, msg.B)
+	}
+}
@@ -1,15 +1,30 @@
 package codec
 
 import (
-	"bytes"
-	"encoding/binary"
 	"errors"
 )
 
 var (
-	ErrBodyNotResponse = errors.New("body is not server response")
+	ErrBodyNotResponse         = errors.New("body is not server response")
+	ErrBodyNotTerminalResponse = errors.New("body is not terminal response")
 )
 
+// serial num, message id and result are common to both general responses
+func encodeGeneralResponse(serialNum, id uint16, result uint8) []byte {
+	var res = make([]byte, 0, 5)
+	res = appendWord(res, serialNum)
+	res = appendWord(res, id)
+	return append(res, result)
+}
+
+func decodeGeneralResponse(data []byte) (serialNum, id uint16, result uint8, err error) {
+	br := newBodyReader(data)
+	serialNum = br.word()
+	id = br.word()
+	result = br.byte()
+	return serialNum, id, result, br.done()
+}
+
 type responseCodec struct {
 }
 
@@ -20,13 +35,42 @@ func (c *responseCodec) Encode(b Body) ([]byte, error) {
 		return nil, ErrBodyNotResponse
 	}
 
-	var res bytes.Buffer
-	binary.Write(&res, binary.BigEndian, r.SerialNum)
-	binary.Write(&res, binary.BigEndian, r.ID)
-	res.Write([]byte{r.Result})
-	return res.Bytes(), nil
+	return encodeGeneralResponse(r.SerialNum, r.ID, r.Result), nil
 }
 
 func (c *responseCodec) Decode(data []byte) (Body, error) {
-	return nil, nil
+	var r ServerResponse
+
+	var err error
+	r.SerialNum, r.ID, r.Result, err = decodeGeneralResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+type terminalResponseCodec struct {
+}
+
+// 0x0001
+func (c *terminalResponseCodec) Encode(b Body) ([]byte, error) {
+	r, ok := b.(*TerminalResponse)
+	if !ok {
+		return nil, ErrBodyNotTerminalResponse
+	}
+
+	return encodeGeneralResponse(r.SerialNum, r.ID, r.Result), nil
+}
+
+func (c *terminalResponseCodec) Decode(data []byte) (Body, error) {
+	var r TerminalResponse
+
+	var err error
+	r.SerialNum, r.ID, r.Result, err = decodeGeneralResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
 }
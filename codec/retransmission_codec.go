@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBodyNotRetransmissionRequest = errors.New("body is not retransmission request")
+)
+
+// RetransmissionRequest asks for missing segments (0x8003, 0x0005 from
+// terminals), the list is a WORD count in 2019 and a BYTE count before.
+type RetransmissionRequest struct {
+	// serial num of the first segment
+	SerialNum   uint16   `json:"serial_num"`
+	SegmentNums []uint16 `json:"segment_nums"`
+}
+
+func (r *RetransmissionRequest) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("serial num: %d\n", r.SerialNum))
+	buf.WriteString(fmt.Sprintf("segment nums: %v\n", r.SegmentNums))
+
+	return buf.String()
+}
+
+type retransmissionRequestCodec struct {
+}
+
+func (c *retransmissionRequestCodec) Encode(b Body) ([]byte, error) {
+	return c.EncodeVersion(b, Version2013)
+}
+
+func (c *retransmissionRequestCodec) Decode(data []byte) (Body, error) {
+	return c.DecodeVersion(data, Version2013)
+}
+
+func (c *retransmissionRequestCodec) EncodeVersion(b Body, version string) ([]byte, error) {
+	r, ok := b.(*RetransmissionRequest)
+	if !ok {
+		return nil, ErrBodyNotRetransmissionRequest
+	}
+
+	var res = make([]byte, 0, 4+2*len(r.SegmentNums))
+	res = appendWord(res, r.SerialNum)
+	if version == Version2019 {
+		res = appendWord(res, uint16(len(r.SegmentNums)))
+	} else {
+		if len(r.SegmentNums) > 0xff {
+			return nil, ErrFieldTooLong
+		}
+		res = append(res, uint8(len(r.SegmentNums)))
+	}
+	for _, n := range r.SegmentNums {
+		res = appendWord(res, n)
+	}
+
+	return res, nil
+}
+
+func (c *retransmissionRequestCodec) DecodeVersion(data []byte, version string) (Body, error) {
+	var r RetransmissionRequest
+
+	br := newBodyReader(data)
+	r.SerialNum = br.word()
+	var count int
+	if version == Version2019 {
+		count = int(br.word())
+	} else {
+		count = int(br.byte())
+	}
+	for i := 0; i < count && br.err == nil; i++ {
+		r.SegmentNums = append(r.SegmentNums, br.word())
+	}
+	if err := br.done(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
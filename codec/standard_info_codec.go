@@ -0,0 +1,511 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInfoLengthMismatch = errors.New("additional info length does not match its id")
+)
+
+// The infos have json tags for their fields. In JSON of location reports
+// every info is wrapped with its id, see jsonInfos in json.go.
+
+// standard additional info ids
+const (
+	InfoIdMileage          uint8 = 0x01
+	InfoIdFuel             uint8 = 0x02
+	InfoIdRecorderSpeed    uint8 = 0x03
+	InfoIdAlarmEventId     uint8 = 0x04
+	InfoIdOverspeed        uint8 = 0x11
+	InfoIdArea             uint8 = 0x12
+	InfoIdRouteDrivingTime uint8 = 0x13
+	InfoIdVehicleSignals   uint8 = 0x25
+	InfoIdIOStatus         uint8 = 0x2a
+	InfoIdAnalog           uint8 = 0x2b
+	InfoIdSignalStrength   uint8 = 0x30
+	InfoIdSatelliteCount   uint8 = 0x31
+)
+
+// area types of overspeed and area alarms
+const (
+	AreaTypeNone      uint8 = 0
+	AreaTypeCircle    uint8 = 1
+	AreaTypeRectangle uint8 = 2
+	AreaTypePolygon   uint8 = 3
+	AreaTypeRoute     uint8 = 4
+)
+
+func areaTypeHuman(t uint8) string {
+	switch t {
+	case AreaTypeNone:
+		return "none"
+	case AreaTypeCircle:
+		return "circle"
+	case AreaTypeRectangle:
+		return "rectangle"
+	case AreaTypePolygon:
+		return "polygon"
+	case AreaTypeRoute:
+		return "route"
+	}
+	return fmt.Sprintf("unknown (%d)", t)
+}
+
+func wordInfo(v uint16) []byte {
+	var info = make([]byte, 2)
+	binary.BigEndian.PutUint16(info, v)
+	return info
+}
+
+func dwordInfo(v uint32) []byte {
+	var info = make([]byte, 4)
+	binary.BigEndian.PutUint32(info, v)
+	return info
+}
+
+// decodeFixedInfo checks that data is exactly n bytes
+func decodeFixedInfo(data []byte, n int) (*bodyReader, error) {
+	if len(data) != n {
+		return nil, ErrInfoLengthMismatch
+	}
+	return newBodyReader(data), nil
+}
+
+// Mileage (0x01) in 1/10 km
+type Mileage struct {
+	Value uint32 `json:"value"`
+}
+
+func (m *Mileage) Id() uint8 {
+	return InfoIdMileage
+}
+
+func (m *Mileage) Length() uint8 {
+	return 4
+}
+
+func (m *Mileage) Info() []byte {
+	return dwordInfo(m.Value)
+}
+
+func (m *Mileage) Km() float64 {
+	return float64(m.Value) / 10
+}
+
+func (m *Mileage) Human() string {
+	return fmt.Sprintf("mileage: %.1f km\n", m.Km())
+}
+
+func decodeMileage(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &Mileage{Value: br.dword()}, nil
+}
+
+// Fuel (0x02) in 1/10 L
+type Fuel struct {
+	Value uint16 `json:"value"`
+}
+
+func (f *Fuel) Id() uint8 {
+	return InfoIdFuel
+}
+
+func (f *Fuel) Length() uint8 {
+	return 2
+}
+
+func (f *Fuel) Info() []byte {
+	return wordInfo(f.Value)
+}
+
+func (f *Fuel) Liters() float64 {
+	return float64(f.Value) / 10
+}
+
+func (f *Fuel) Human() string {
+	return fmt.Sprintf("fuel: %.1f L\n", f.Liters())
+}
+
+func decodeFuel(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Fuel{Value: br.word()}, nil
+}
+
+// RecorderSpeed (0x03) is the tachograph speed in 1/10 km/h
+type RecorderSpeed struct {
+	Value uint16 `json:"value"`
+}
+
+func (r *RecorderSpeed) Id() uint8 {
+	return InfoIdRecorderSpeed
+}
+
+func (r *RecorderSpeed) Length() uint8 {
+	return 2
+}
+
+func (r *RecorderSpeed) Info() []byte {
+	return wordInfo(r.Value)
+}
+
+func (r *RecorderSpeed) Kmh() float64 {
+	return float64(r.Value) / 10
+}
+
+func (r *RecorderSpeed) Human() string {
+	return fmt.Sprintf("recorder speed: %.1f km/h\n", r.Kmh())
+}
+
+func decodeRecorderSpeed(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &RecorderSpeed{Value: br.word()}, nil
+}
+
+// AlarmEventId (0x04) identifies an alarm that needs manual confirmation
+type AlarmEventId struct {
+	Value uint16 `json:"value"`
+}
+
+func (a *AlarmEventId) Id() uint8 {
+	return InfoIdAlarmEventId
+}
+
+func (a *AlarmEventId) Length() uint8 {
+	return 2
+}
+
+func (a *AlarmEventId) Info() []byte {
+	return wordInfo(a.Value)
+}
+
+func (a *AlarmEventId) Human() string {
+	return fmt.Sprintf("alarm event id: %d\n", a.Value)
+}
+
+func decodeAlarmEventId(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &AlarmEventId{Value: br.word()}, nil
+}
+
+// OverspeedInfo (0x11) comes with the overspeed alarm, AreaId is absent if
+// AreaType is AreaTypeNone
+type OverspeedInfo struct {
+	AreaType uint8  `json:"area_type"`
+	AreaId   uint32 `json:"area_id"`
+}
+
+func (o *OverspeedInfo) Id() uint8 {
+	return InfoIdOverspeed
+}
+
+func (o *OverspeedInfo) Length() uint8 {
+	return uint8(len(o.Info()))
+}
+
+func (o *OverspeedInfo) Info() []byte {
+	if o.AreaType == AreaTypeNone {
+		return []byte{o.AreaType}
+	}
+	return append([]byte{o.AreaType}, dwordInfo(o.AreaId)...)
+}
+
+func (o *OverspeedInfo) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("overspeed area type: %s\n", areaTypeHuman(o.AreaType)))
+	if o.AreaType != AreaTypeNone {
+		buf.WriteString(fmt.Sprintf("overspeed area id: %d\n", o.AreaId))
+	}
+
+	return buf.String()
+}
+
+// the area id is there if and only if the area type is not none
+func decodeOverspeedInfo(data []byte) (LocationAdditionalInfo, error) {
+	var n = 5
+	if len(data) > 0 && data[0] == AreaTypeNone {
+		n = 1
+	}
+	br, err := decodeFixedInfo(data, n)
+	if err != nil {
+		return nil, err
+	}
+	var info = &OverspeedInfo{AreaType: br.byte()}
+	if n == 5 {
+		info.AreaId = br.dword()
+	}
+	return info, nil
+}
+
+// area alarm directions
+const (
+	AreaDirectionIn  uint8 = 0
+	AreaDirectionOut uint8 = 1
+)
+
+// AreaInfo (0x12) comes with the area or route in/out alarm
+type AreaInfo struct {
+	AreaType  uint8  `json:"area_type"`
+	AreaId    uint32 `json:"area_id"`
+	Direction uint8  `json:"direction"`
+}
+
+func (a *AreaInfo) Id() uint8 {
+	return InfoIdArea
+}
+
+func (a *AreaInfo) Length() uint8 {
+	return 6
+}
+
+func (a *AreaInfo) Info() []byte {
+	var info = []byte{a.AreaType}
+	info = append(info, dwordInfo(a.AreaId)...)
+	return append(info, a.Direction)
+}
+
+func (a *AreaInfo) Human() string {
+	var buf bytes.Buffer
+
+	var direction = "in"
+	if a.Direction == AreaDirectionOut {
+		direction = "out"
+	}
+
+	buf.WriteString(fmt.Sprintf("area type: %s\n", areaTypeHuman(a.AreaType)))
+	buf.WriteString(fmt.Sprintf("area id: %d\n", a.AreaId))
+	buf.WriteString(fmt.Sprintf("area direction: %s\n", direction))
+
+	return buf.String()
+}
+
+func decodeAreaInfo(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 6)
+	if err != nil {
+		return nil, err
+	}
+	return &AreaInfo{AreaType: br.byte(), AreaId: br.dword(), Direction: br.byte()}, nil
+}
+
+// route driving time results
+const (
+	RouteDrivingTimeShort uint8 = 0
+	RouteDrivingTimeLong  uint8 = 1
+)
+
+// RouteDrivingTime (0x13) comes with the route driving time alarm
+type RouteDrivingTime struct {
+	RouteId uint32 `json:"route_id"`
+	// seconds
+	DrivingTime uint16 `json:"driving_time"`
+	Result      uint8  `json:"result"`
+}
+
+func (r *RouteDrivingTime) Id() uint8 {
+	return InfoIdRouteDrivingTime
+}
+
+func (r *RouteDrivingTime) Length() uint8 {
+	return 7
+}
+
+func (r *RouteDrivingTime) Info() []byte {
+	var info = dwordInfo(r.RouteId)
+	info = append(info, wordInfo(r.DrivingTime)...)
+	return append(info, r.Result)
+}
+
+func (r *RouteDrivingTime) Human() string {
+	var buf bytes.Buffer
+
+	var result = "too short"
+	if r.Result == RouteDrivingTimeLong {
+		result = "too long"
+	}
+
+	buf.WriteString(fmt.Sprintf("route id: %d\n", r.RouteId))
+	buf.WriteString(fmt.Sprintf("route driving time: %ds\n", r.DrivingTime))
+	buf.WriteString(fmt.Sprintf("route driving time result: %s\n", result))
+
+	return buf.String()
+}
+
+func decodeRouteDrivingTime(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 7)
+	if err != nil {
+		return nil, err
+	}
+	return &RouteDrivingTime{RouteId: br.dword(), DrivingTime: br.word(), Result: br.byte()}, nil
+}
+
+// VehicleSignals (0x25) is the extended vehicle signal bitfield, e.g. bit 0
+// low beam, bit 3 right and bit 4 left turn signal, bit 5 brake
+type VehicleSignals struct {
+	Value uint32 `json:"value"`
+}
+
+func (v *VehicleSignals) Id() uint8 {
+	return InfoIdVehicleSignals
+}
+
+func (v *VehicleSignals) Length() uint8 {
+	return 4
+}
+
+func (v *VehicleSignals) Info() []byte {
+	return dwordInfo(v.Value)
+}
+
+func (v *VehicleSignals) Human() string {
+	return fmt.Sprintf("vehicle signals: %032b\n", v.Value)
+}
+
+func decodeVehicleSignals(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &VehicleSignals{Value: br.dword()}, nil
+}
+
+// IOStatus (0x2A) bit 0 is deep sleep, bit 1 sleep
+type IOStatus struct {
+	Value uint16 `json:"value"`
+}
+
+func (i *IOStatus) Id() uint8 {
+	return InfoIdIOStatus
+}
+
+func (i *IOStatus) Length() uint8 {
+	return 2
+}
+
+func (i *IOStatus) Info() []byte {
+	return wordInfo(i.Value)
+}
+
+func (i *IOStatus) DeepSleep() bool {
+	return i.Value&0x01 != 0
+}
+
+func (i *IOStatus) Sleep() bool {
+	return i.Value&0x02 != 0
+}
+
+func (i *IOStatus) Human() string {
+	return fmt.Sprintf("io status: deep sleep %v, sleep %v\n", i.DeepSleep(), i.Sleep())
+}
+
+func decodeIOStatus(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &IOStatus{Value: br.word()}, nil
+}
+
+// Analog (0x2B) carries AD0 in the low and AD1 in the high word
+type Analog struct {
+	AD0 uint16 `json:"ad0"`
+	AD1 uint16 `json:"ad1"`
+}
+
+func (a *Analog) Id() uint8 {
+	return InfoIdAnalog
+}
+
+func (a *Analog) Length() uint8 {
+	return 4
+}
+
+func (a *Analog) Info() []byte {
+	return dwordInfo(uint32(a.AD1)<<16 | uint32(a.AD0))
+}
+
+func (a *Analog) Human() string {
+	return fmt.Sprintf("analog: ad0 %d, ad1 %d\n", a.AD0, a.AD1)
+}
+
+func decodeAnalog(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 4)
+	if err != nil {
+		return nil, err
+	}
+	v := br.dword()
+	return &Analog{AD0: uint16(v), AD1: uint16(v >> 16)}, nil
+}
+
+// SignalStrength (0x30) of the wireless network
+type SignalStrength struct {
+	Value uint8 `json:"value"`
+}
+
+func (s *SignalStrength) Id() uint8 {
+	return InfoIdSignalStrength
+}
+
+func (s *SignalStrength) Length() uint8 {
+	return 1
+}
+
+func (s *SignalStrength) Info() []byte {
+	return []byte{s.Value}
+}
+
+func (s *SignalStrength) Human() string {
+	return fmt.Sprintf("signal strength: %d\n", s.Value)
+}
+
+func decodeSignalStrength(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &SignalStrength{Value: br.byte()}, nil
+}
+
+// SatelliteCount (0x31) of GNSS satellites in use
+type SatelliteCount struct {
+	Value uint8 `json:"value"`
+}
+
+func (s *SatelliteCount) Id() uint8 {
+	return InfoIdSatelliteCount
+}
+
+func (s *SatelliteCount) Length() uint8 {
+	return 1
+}
+
+func (s *SatelliteCount) Info() []byte {
+	return []byte{s.Value}
+}
+
+func (s *SatelliteCount) Human() string {
+	return fmt.Sprintf("gnss satellites: %d\n", s.Value)
+}
+
+func decodeSatelliteCount(data []byte) (LocationAdditionalInfo, error) {
+	br, err := decodeFixedInfo(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &SatelliteCount{Value: br.byte()}, nil
+}
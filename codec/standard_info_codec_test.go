@@ -0,0 +1,49 @@
+package codec
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestStandardInfos_RoundTrip(t *testing.T) {
+	var c locationAdditionalInfoCodec
+
+	var infos = []LocationAdditionalInfo{
+		&Mileage{Value: 123456},
+		&Fuel{Value: 455},
+		&RecorderSpeed{Value: 602},
+		&AlarmEventId{Value: 7},
+		&OverspeedInfo{AreaType: AreaTypeNone},
+		&OverspeedInfo{AreaType: AreaTypeCircle, AreaId: 42},
+		&AreaInfo{AreaType: AreaTypePolygon, AreaId: 9, Direction: AreaDirectionOut},
+		&RouteDrivingTime{RouteId: 3, DrivingTime: 1800, Result: RouteDrivingTimeLong},
+		&VehicleSignals{Value: 0x21},
+		&IOStatus{Value: 0x02},
+		&Analog{AD0: 1, AD1: 2},
+		&SignalStrength{Value: 25},
+		&SatelliteCount{Value: 11},
+	}
+
+	data, err := c.Encode(infos)
+	assert.Equal(t, nil, err)
+
+	decoded, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, infos, decoded)
+	for _, info := range decoded {
+		assert.Equal(t, int(info.Length()), len(info.Info()))
+	}
+
+	assert.Equal(t, 45.5, decoded[1].(*Fuel).Liters())
+	assert.Equal(t, true, decoded[9].(*IOStatus).Sleep())
+	assert.Equal(t, []byte{0x00, 0x02, 0x00, 0x01}, decoded[10].Info())
+}
+
+func TestStandardInfos_LengthMismatch(t *testing.T) {
+	var c locationAdditionalInfoCodec
+
+	decoded, err := c.Decode([]byte{0x01, 0x02, 0x00, 0x10, 0x30, 0x01, 0x1f})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewUnknownInfo(0x01, []byte{0x00, 0x10}), decoded[0])
+	assert.Equal(t, &SignalStrength{Value: 0x1f}, decoded[1])
+}
@@ -0,0 +1,274 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+var (
+	ErrBodyNotTerminalControl = errors.New("body is not terminal control")
+	ErrInvalidControlParams   = errors.New("invalid terminal control params")
+)
+
+// terminal control commands
+const (
+	ControlUpgrade       uint8 = 1
+	ControlConnectServer uint8 = 2
+	ControlPowerOff      uint8 = 3
+	ControlReset         uint8 = 4
+	ControlFactoryReset  uint8 = 5
+	ControlCloseDataLink uint8 = 6
+	ControlCloseWireless uint8 = 7
+)
+
+// connect server controls
+const (
+	// connect to the given server until the time limit
+	ConnectSpecifiedServer uint8 = 0
+	// switch back to the original server, the other params are left out
+	ConnectOriginalServer uint8 = 1
+)
+
+// UpgradeParams are the params of a wireless upgrade
+type UpgradeParams struct {
+	URL             string `json:"url"`
+	APN             string `json:"apn"`
+	User            string `json:"user"`
+	Password        string `json:"password"`
+	Address         string `json:"address"`
+	TCPPort         uint16 `json:"tcp_port"`
+	UDPPort         uint16 `json:"udp_port"`
+	ManufacturerId  string `json:"manufacturer_id"`
+	HardwareVersion string `json:"hardware_version"`
+	FirmwareVersion string `json:"firmware_version"`
+	// minutes
+	TimeLimit uint16 `json:"time_limit"`
+}
+
+// ConnectParams are the params of connecting to a specified server
+type ConnectParams struct {
+	// ConnectSpecifiedServer or ConnectOriginalServer
+	Control  uint8  `json:"control"`
+	AuthCode string `json:"auth_code"`
+	APN      string `json:"apn"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Address  string `json:"address"`
+	TCPPort  uint16 `json:"tcp_port"`
+	UDPPort  uint16 `json:"udp_port"`
+	// minutes
+	TimeLimit uint16 `json:"time_limit"`
+}
+
+// TerminalControl is the terminal control (0x8105) body. Upgrade and
+// Connect are the params of ControlUpgrade and ControlConnectServer, the
+// other commands have none.
+type TerminalControl struct {
+	Command uint8          `json:"command"`
+	Upgrade *UpgradeParams `json:"upgrade,omitempty"`
+	Connect *ConnectParams `json:"connect,omitempty"`
+}
+
+func (t *TerminalControl) Human() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("command: %s\n", controlCommandHuman(t.Command)))
+	if params, err := t.params(); err == nil && len(params) > 0 {
+		buf.WriteString(fmt.Sprintf("params: %s\n", strings.Join(params, ";")))
+	}
+
+	return buf.String()
+}
+
+func controlCommandHuman(command uint8) string {
+	switch command {
+	case ControlUpgrade:
+		return "wireless upgrade"
+	case ControlConnectServer:
+		return "connect server"
+	case ControlPowerOff:
+		return "power off"
+	case ControlReset:
+		return "reset"
+	case ControlFactoryReset:
+		return "factory reset"
+	case ControlCloseDataLink:
+		return "close data link"
+	case ControlCloseWireless:
+		return "close wireless links"
+	}
+	return fmt.Sprintf("unknown (%d)", command)
+}
+
+// numbers are left empty if zero
+func controlNum(n uint16) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(n))
+}
+
+func parseControlNum(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, ErrInvalidControlParams
+	}
+	return uint16(n), nil
+}
+
+// params returns the semicolon separated params of the command
+func (t *TerminalControl) params() ([]string, error) {
+	var params []string
+
+	switch t.Command {
+	case ControlUpgrade:
+		u := t.Upgrade
+		if u == nil {
+			return nil, ErrInvalidControlParams
+		}
+		params = []string{
+			u.URL, u.APN, u.User, u.Password, u.Address,
+			controlNum(u.TCPPort), controlNum(u.UDPPort),
+			u.ManufacturerId, u.HardwareVersion, u.FirmwareVersion,
+			controlNum(u.TimeLimit),
+		}
+	case ControlConnectServer:
+		c := t.Connect
+		if c == nil {
+			return nil, ErrInvalidControlParams
+		}
+		params = []string{strconv.Itoa(int(c.Control))}
+		if c.Control != ConnectOriginalServer {
+			params = append(params,
+				c.AuthCode, c.APN, c.User, c.Password, c.Address,
+				controlNum(c.TCPPort), controlNum(c.UDPPort),
+				controlNum(c.TimeLimit),
+			)
+		}
+	}
+
+	for _, p := range params {
+		if strings.Contains(p, ";") {
+			return nil, ErrInvalidControlParams
+		}
+	}
+
+	return params, nil
+}
+
+type terminalControlCodec struct {
+}
+
+// 0x8105
+func (c *terminalControlCodec) Encode(b Body) ([]byte, error) {
+	t, ok := b.(*TerminalControl)
+	if !ok {
+		return nil, ErrBodyNotTerminalControl
+	}
+
+	params, err := t.params()
+	if err != nil {
+		return nil, err
+	}
+
+	var res = []byte{t.Command}
+	if len(params) == 0 {
+		return res, nil
+	}
+
+	paramBytes, err := utils.EncodeGBK(strings.Join(params, ";"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(res, paramBytes...), nil
+}
+
+func (c *terminalControlCodec) Decode(data []byte) (Body, error) {
+	if len(data) < 1 {
+		return nil, &DecodeError{Offset: 0, Err: ErrTruncated}
+	}
+
+	var t = TerminalControl{Command: data[0]}
+
+	paramStr, err := utils.DecodeGBK(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	params := strings.Split(paramStr, ";")
+
+	var nums []uint16
+	parseNums := func(ss ...string) error {
+		nums = nums[:0]
+		for _, s := range ss {
+			n, err := parseControlNum(s)
+			if err != nil {
+				return err
+			}
+			nums = append(nums, n)
+		}
+		return nil
+	}
+
+	switch t.Command {
+	case ControlUpgrade:
+		if len(params) != 11 {
+			return nil, ErrInvalidControlParams
+		}
+		if err := parseNums(params[5], params[6], params[10]); err != nil {
+			return nil, err
+		}
+		t.Upgrade = &UpgradeParams{
+			URL:             params[0],
+			APN:             params[1],
+			User:            params[2],
+			Password:        params[3],
+			Address:         params[4],
+			TCPPort:         nums[0],
+			UDPPort:         nums[1],
+			ManufacturerId:  params[7],
+			HardwareVersion: params[8],
+			FirmwareVersion: params[9],
+			TimeLimit:       nums[2],
+		}
+	case ControlConnectServer:
+		control, err := strconv.ParseUint(params[0], 10, 8)
+		if err != nil {
+			return nil, ErrInvalidControlParams
+		}
+		t.Connect = &ConnectParams{Control: uint8(control)}
+		if t.Connect.Control == ConnectOriginalServer {
+			break
+		}
+		if len(params) != 9 {
+			return nil, ErrInvalidControlParams
+		}
+		if err := parseNums(params[6], params[7], params[8]); err != nil {
+			return nil, err
+		}
+		t.Connect.AuthCode = params[1]
+		t.Connect.APN = params[2]
+		t.Connect.User = params[3]
+		t.Connect.Password = params[4]
+		t.Connect.Address = params[5]
+		t.Connect.TCPPort = nums[0]
+		t.Connect.UDPPort = nums[1]
+		t.Connect.TimeLimit = nums[2]
+	}
+
+	// params are text, e.g. ports with leading zeros, only the form Encode
+	// writes is accepted so that the body encodes to the same bytes
+	if encoded, err := c.Encode(&t); err != nil || !bytes.Equal(encoded, data) {
+		return nil, ErrInvalidControlParams
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestTerminalControlCodec_Encode(t *testing.T) {
+	var c terminalControlCodec
+
+	for _, tc := range []struct {
+		body   *TerminalControl
+		params string
+	}{
+		{&TerminalControl{Command: ControlReset}, ""},
+		{&TerminalControl{
+			Command: ControlUpgrade,
+			Upgrade: &UpgradeParams{
+				URL:             "http://example.com/fw.bin",
+				ManufacturerId:  "70111",
+				HardwareVersion: "hw1",
+				FirmwareVersion: "fw2",
+				TimeLimit:       30,
+			},
+		}, "http://example.com/fw.bin;;;;;;;70111;hw1;fw2;30"},
+		{&TerminalControl{
+			Command: ControlConnectServer,
+			Connect: &ConnectParams{
+				Control:   ConnectSpecifiedServer,
+				AuthCode:  "abc",
+				Address:   "10.0.0.1",
+				TCPPort:   8808,
+				TimeLimit: 10,
+			},
+		}, "0;abc;;;;10.0.0.1;8808;;10"},
+		{&TerminalControl{
+			Command: ControlConnectServer,
+			Connect: &ConnectParams{Control: ConnectOriginalServer, Address: "ignored"},
+		}, "1"},
+	} {
+		data, err := c.Encode(tc.body)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, tc.body.Command, data[0])
+		assert.Equal(t, tc.params, string(data[1:]))
+
+		decoded, err := c.Decode(data)
+		assert.Equal(t, nil, err)
+		if tc.body.Connect != nil && tc.body.Connect.Control == ConnectOriginalServer {
+			continue
+		}
+		assert.Equal(t, tc.body, decoded)
+	}
+}
+
+func TestTerminalControlCodec_InvalidParams(t *testing.T) {
+	var c terminalControlCodec
+
+	_, err := c.Encode(&TerminalControl{Command: ControlUpgrade})
+	assert.Equal(t, ErrInvalidControlParams, err)
+
+	_, err = c.Encode(&TerminalControl{
+		Command: ControlUpgrade,
+		Upgrade: &UpgradeParams{URL: "http://example.com/a;b"},
+	})
+	assert.Equal(t, ErrInvalidControlParams, err)
+}
+
+func TestTerminalControl_CodecEncode(t *testing.T) {
+	var c, _ = NewCodec(nil)
+
+	data, err := c.Encode(&Message{
+		H: &Header{MessageId: MessageIdTerminalControl, Phone: 19161017001},
+		B: &TerminalControl{Command: ControlFactoryReset},
+	})
+	assert.Equal(t, nil, err)
+
+	msg, err := c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &TerminalControl{Command: ControlFactoryReset}, msg.B)
+}
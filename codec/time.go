@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sceneryback/jtt808/utils"
+)
+
+const (
+	// BCD[6] YYMMDDhhmmss, years are 20YY
+	timeLengthBCD = 6
+)
+
+var (
+	// DefaultTimeZone is the time zone of BCD timestamps, GMT+8 as the spec
+	// says, unless CodecConfig sets another
+	DefaultTimeZone = time.FixedZone("GMT+8", 8*60*60)
+
+	ErrInvalidTimestamp = errors.New("invalid BCD timestamp")
+)
+
+// TimestampError reports a BCD timestamp that is not a valid date, it matches
+// ErrInvalidTimestamp with errors.Is
+type TimestampError struct {
+	BCD []byte
+}
+
+func (e *TimestampError) Error() string {
+	return fmt.Sprintf("%s: %x", ErrInvalidTimestamp, e.BCD)
+}
+
+func (e *TimestampError) Is(target error) bool {
+	return target == ErrInvalidTimestamp
+}
+
+// decodeBCDTime returns the zero time for all-zero timestamps, which devices
+// send before they are positioned
+func decodeBCDTime(data []byte, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = DefaultTimeZone
+	}
+	if len(data) != timeLengthBCD {
+		return time.Time{}, &TimestampError{BCD: data}
+	}
+
+	var fields [timeLengthBCD]int
+	var zero = true
+	for i, b := range data {
+		n, ok := utils.ParseBCDUint(data[i : i+1])
+		if !ok {
+			return time.Time{}, &TimestampError{BCD: data}
+		}
+		fields[i] = int(n)
+		zero = zero && b == 0
+	}
+	if zero {
+		return time.Time{}, nil
+	}
+
+	year, month, day := 2000+fields[0], time.Month(fields[1]), fields[2]
+	hour, min, sec := fields[3], fields[4], fields[5]
+	if hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, &TimestampError{BCD: data}
+	}
+	ts := time.Date(year, month, day, hour, min, sec, 0, loc)
+	// time.Date normalizes e.g. February 30th into March
+	if ts.Month() != month || ts.Day() != day {
+		return time.Time{}, &TimestampError{BCD: data}
+	}
+	return ts, nil
+}
+
+// encodeBCDTime encodes the zero time as all zeros
+func encodeBCDTime(t time.Time, loc *time.Location) []byte {
+	return appendBCDTime(make([]byte, 0, timeLengthBCD), t, loc)
+}
+
+func appendBCDTime(dst []byte, t time.Time, loc *time.Location) []byte {
+	if loc == nil {
+		loc = DefaultTimeZone
+	}
+	if t.IsZero() {
+		return append(dst, 0, 0, 0, 0, 0, 0)
+	}
+
+	t = t.In(loc)
+	for _, n := range []int{t.Year() % 100, int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second()} {
+		dst = append(dst, uint8(n/10)<<4|uint8(n%10))
+	}
+	return dst
+}
@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestDecodeBCDTime(t *testing.T) {
+	ts, err := decodeBCDTime([]byte{0x16, 0x10, 0x17, 0x10, 0x27, 0x56}, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1476671276), ts.Unix())
+
+	ts, err = decodeBCDTime([]byte{0x16, 0x10, 0x17, 0x10, 0x27, 0x56}, time.UTC)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1476671276+8*3600), ts.Unix())
+
+	// years 2000-2009
+	ts, err = decodeBCDTime([]byte{0x09, 0x01, 0x02, 0x03, 0x04, 0x05}, time.UTC)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, time.Date(2009, 1, 2, 3, 4, 5, 0, time.UTC), ts)
+
+	// unpositioned devices
+	ts, err = decodeBCDTime(make([]byte, 6), nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ts.IsZero())
+	assert.Equal(t, make([]byte, 6), encodeBCDTime(ts, nil))
+
+	_, err = decodeBCDTime([]byte{0x16, 0x13, 0x17, 0x10, 0x27, 0x56}, nil)
+	assert.Equal(t, true, errors.Is(err, ErrInvalidTimestamp))
+	assert.Equal(t, "invalid BCD timestamp: 161317102756", err.Error())
+}
+
+func TestCodec_TimeZone(t *testing.T) {
+	data, _ := hex.DecodeString(sampleLocationFrame)
+
+	utc, _ := NewCodec(&CodecConfig{TimeZone: time.UTC})
+	msg, err := utc.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, time.Date(2016, 10, 17, 10, 27, 56, 0, time.UTC), msg.B.(*LocationMsgBody).Basic.Timestamp)
+
+	encoded, err := utc.Encode(msg)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sampleLocationFrame, hex.EncodeToString(encoded))
+
+	c, _ := NewCodec(nil)
+	msg, err = c.Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1476671276), msg.B.(*LocationMsgBody).Basic.Timestamp.Unix())
+
+	msg, err = c.WithTimeZone(time.UTC).Decode(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1476671276+8*3600), msg.B.(*LocationMsgBody).Basic.Timestamp.Unix())
+
+	// unpositioned
+	basic := msg.B.(*LocationMsgBody).Basic
+	basic.Timestamp = time.Time{}
+	encoded, _ = c.Encode(msg)
+	msg, err = c.Decode(encoded)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, msg.B.(*LocationMsgBody).Basic.Timestamp.IsZero())
+}
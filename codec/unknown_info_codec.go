@@ -12,6 +12,15 @@ type UnknownInfo struct {
 	body   []byte
 }
 
+// NewUnknownInfo wraps an additional info with no typed representation
+func NewUnknownInfo(id uint8, body []byte) *UnknownInfo {
+	return &UnknownInfo{
+		id:     id,
+		length: uint8(len(body)),
+		body:   body,
+	}
+}
+
 func (u *UnknownInfo) Id() uint8 {
 	return u.id
 }
@@ -35,16 +44,3 @@ func (u *UnknownInfo) Human() string {
 
 	return buf.String()
 }
-
-type unknownInfoCodec struct {
-}
-
-func (c *unknownInfoCodec) Decode(data []byte) (*UnknownInfo, error) {
-	var info UnknownInfo
-
-	info.id = uint8(data[0])
-	info.length = uint8(data[1])
-	info.body = data[2:]
-
-	return &info, nil
-}
@@ -8,13 +8,14 @@ import (
 )
 
 type Wifi struct {
-	MacAddress     string
-	SignalStrength uint8
+	MacAddress     string `json:"mac_address"`
+	SignalStrength uint8  `json:"signal_strength"`
 }
 
 type AdditionalInfoWifis struct {
-	Wifis []*Wifi
-	Raw   []byte
+	Wifis []*Wifi `json:"wifis"`
+	// decoded bytes, Info() is built from Wifis
+	Raw []byte `json:"-"`
 }
 
 func (a *AdditionalInfoWifis) Id() uint8 {
@@ -25,8 +26,18 @@ func (a *AdditionalInfoWifis) Length() uint8 {
 	return uint8(len(a.Wifis)*7 + 1)
 }
 
+// Info encodes an invalid mac address as 00:00:00:00:00:00
 func (a *AdditionalInfoWifis) Info() []byte {
-	return a.Raw
+	var info = []byte{uint8(len(a.Wifis))}
+	for i := range a.Wifis {
+		mac, err := utils.MacAddrToHexBytes(a.Wifis[i].MacAddress)
+		if err != nil || len(mac) != 6 {
+			mac = make([]byte, 6)
+		}
+		info = append(info, mac...)
+		info = append(info, ^a.Wifis[i].SignalStrength+1)
+	}
+	return info
 }
 
 func (a *AdditionalInfoWifis) Human() string {
@@ -45,18 +56,32 @@ type wifiCodec struct {
 }
 
 func (w *wifiCodec) Decode(data []byte) (*AdditionalInfoWifis, error) {
+	if len(data) < 1 {
+		return nil, &DecodeError{Offset: 0, Err: ErrTruncated}
+	}
+
 	var wifis = AdditionalInfoWifis{
 		Raw: data,
 	}
 
 	wifisNum := int(data[0])
 	data = data[1:]
+	if len(data) < wifisNum*7 {
+		return nil, &DecodeError{Offset: 1 + len(data), Err: ErrTruncated}
+	}
+	if len(data) > wifisNum*7 {
+		return nil, ErrInfoLengthMismatch
+	}
 
-	for i := 0; i < wifisNum; i++ {
-		wifis.Wifis = append(wifis.Wifis, &Wifi{
+	// one allocation for all the wifis
+	var list = make([]Wifi, wifisNum)
+	wifis.Wifis = make([]*Wifi, wifisNum)
+	for i := range list {
+		list[i] = Wifi{
 			MacAddress:     utils.HexBytesToMacAddr(data[i*7 : i*7+6]),
 			SignalStrength: ^uint8(data[i*7+6]) + 1,
-		})
+		}
+		wifis.Wifis[i] = &list[i]
 	}
 
 	return &wifis, nil
@@ -1,9 +1,17 @@
 module github.com/sceneryback/jtt808
 
-go 1.13
+go 1.18
 
 require (
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869
 	github.com/json-iterator/go v1.1.12
+	golang.org/x/text v0.3.3
+)
+
+require (
 	github.com/kr/pretty v0.3.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/rogpeppe/go-internal v1.6.1 // indirect
 )
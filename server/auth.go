@@ -0,0 +1,276 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/sceneryback/jtt808/codec"
+)
+
+// Authenticator issues auth codes to registering terminals (0x0100) and
+// verifies the codes they authenticate with (0x0102).
+type Authenticator interface {
+	// Register returns the auth code for the terminal, or a non-success
+	// register result such as codec.RegisterResultTerminalNotFound
+	Register(phone uint64, body *codec.RegisterMsgBody) (authCode string, result uint8, err error)
+	// Verify reports whether authCode was issued to the terminal
+	Verify(phone uint64, authCode string) (bool, error)
+}
+
+// MemoryAuthenticator keeps auth codes in memory. A terminal registering
+// again gets RegisterResultTerminalRegistered and no code, the code is only
+// ever sent to the first registration. A terminal that lost its code
+// registers anew once the code is revoked, see Revoke.
+type MemoryAuthenticator struct {
+	mu    sync.RWMutex
+	codes map[uint64]string
+}
+
+func NewMemoryAuthenticator() *MemoryAuthenticator {
+	return &MemoryAuthenticator{
+		codes: make(map[uint64]string),
+	}
+}
+
+// SetAuthCode provisions a terminal that skips registration, it never fails
+func (a *MemoryAuthenticator) SetAuthCode(phone uint64, authCode string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.codes[phone] = authCode
+	return nil
+}
+
+// Revoke drops the auth code of the terminal, its next registration gets a
+// new one. It never fails.
+func (a *MemoryAuthenticator) Revoke(phone uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.codes, phone)
+	return nil
+}
+
+func (a *MemoryAuthenticator) Register(phone uint64, body *codec.RegisterMsgBody) (string, uint8, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.codes[phone]; ok {
+		return "", codec.RegisterResultTerminalRegistered, nil
+	}
+
+	authCode, err := newAuthCode()
+	if err != nil {
+		return "", 0, err
+	}
+	a.codes[phone] = authCode
+
+	return authCode, codec.RegisterResultSuccess, nil
+}
+
+func (a *MemoryAuthenticator) Verify(phone uint64, authCode string) (bool, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	expected, ok := a.codes[phone]
+	return ok && expected == authCode, nil
+}
+
+func newAuthCode() (string, error) {
+	var bs = make([]byte, 8)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+// FileAuthenticator is a MemoryAuthenticator saved to a json file of phone
+// numbers to auth codes after every newly issued code.
+type FileAuthenticator struct {
+	*MemoryAuthenticator
+
+	path string
+	// serializes saves
+	saveMu sync.Mutex
+}
+
+// NewFileAuthenticator loads the auth codes in path, a missing file is
+// created on the first registration.
+func NewFileAuthenticator(path string) (*FileAuthenticator, error) {
+	var a = &FileAuthenticator{
+		MemoryAuthenticator: NewMemoryAuthenticator(),
+		path:                path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+
+	var codes map[string]string
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return nil, err
+	}
+	for k, v := range codes {
+		phone, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		a.codes[phone] = v
+	}
+
+	return a, nil
+}
+
+func (a *FileAuthenticator) SetAuthCode(phone uint64, authCode string) error {
+	a.MemoryAuthenticator.SetAuthCode(phone, authCode)
+	return a.save()
+}
+
+func (a *FileAuthenticator) Revoke(phone uint64) error {
+	a.MemoryAuthenticator.Revoke(phone)
+	return a.save()
+}
+
+func (a *FileAuthenticator) Register(phone uint64, body *codec.RegisterMsgBody) (string, uint8, error) {
+	authCode, result, err := a.MemoryAuthenticator.Register(phone, body)
+	if err != nil || result != codec.RegisterResultSuccess {
+		return authCode, result, err
+	}
+	if err := a.save(); err != nil {
+		return "", 0, err
+	}
+	return authCode, result, nil
+}
+
+// save writes a temporary file and renames it over path
+func (a *FileAuthenticator) save() error {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	var codes = make(map[string]string)
+	a.mu.RLock()
+	for phone, authCode := range a.codes {
+		codes[strconv.FormatUint(phone, 10)] = authCode
+	}
+	a.mu.RUnlock()
+
+	data, err := json.MarshalIndent(codes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(a.path), filepath.Base(a.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
+
+// authorize handles register and auth messages itself, and reports whether
+// msg may be passed on to the handlers. Once authenticated, a session only
+// accepts messages of the phone it authenticated as, even after a failed
+// auth.
+func (s *Session) authorize(a Authenticator, msg *codec.Message) bool {
+	s.mu.Lock()
+	var authenticated = s.authenticated
+	// the phone is set by authenticate only
+	var otherPhone = s.phone != 0 && s.phone != msg.H.Phone
+	s.mu.Unlock()
+
+	if otherPhone {
+		s.Reply(msg, codec.ResultFailure)
+		return false
+	}
+
+	switch msg.H.MessageId {
+	case codec.MessageIdTerminalRegister:
+		s.register(a, msg)
+		return false
+	case codec.MessageIdTerminalAuth:
+		s.authenticate(a, msg)
+		return false
+	}
+
+	if !authenticated {
+		s.Reply(msg, codec.ResultFailure)
+		return false
+	}
+	return true
+}
+
+func (s *Session) register(a Authenticator, msg *codec.Message) {
+	body, ok := msg.B.(*codec.RegisterMsgBody)
+	if !ok {
+		s.Reply(msg, codec.ResultFailure)
+		return
+	}
+
+	authCode, result, err := a.Register(msg.H.Phone, body)
+	if err != nil {
+		s.server.logf("jtt808: failed to register %d: %s", msg.H.Phone, err)
+		s.Reply(msg, codec.ResultFailure)
+		return
+	}
+
+	s.Send(&codec.Message{
+		H: &codec.Header{
+			MessageId: codec.MessageIdRegisterResponse,
+			Attr:      &codec.BodyAttr{},
+		},
+		B: &codec.RegisterResponse{
+			SerialNum: msg.H.SerialNum,
+			Result:    result,
+			AuthCode:  authCode,
+		},
+	})
+}
+
+func (s *Session) authenticate(a Authenticator, msg *codec.Message) {
+	body, ok := msg.B.(*codec.AuthMsgBody)
+	if !ok {
+		s.Reply(msg, codec.ResultFailure)
+		return
+	}
+
+	verified, err := a.Verify(msg.H.Phone, body.AuthCode)
+	if err != nil {
+		s.server.logf("jtt808: failed to verify %d: %s", msg.H.Phone, err)
+	}
+
+	s.mu.Lock()
+	var prev = s.phone
+	s.authenticated = verified
+	if verified {
+		s.phone = msg.H.Phone
+	}
+	s.mu.Unlock()
+
+	if !verified {
+		s.Reply(msg, codec.ResultFailure)
+		return
+	}
+	// the terminal is routed to this session only now
+	if prev != msg.H.Phone {
+		s.server.bind(s, prev, msg.H.Phone)
+	}
+	s.Reply(msg, codec.ResultSuccess)
+}
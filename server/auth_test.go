@@ -0,0 +1,196 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/sceneryback/jtt808/codec"
+)
+
+// dialTerminal connects to addr, roundTrip sends a message as phone and reads
+// the response
+func dialTerminal(t *testing.T, addr net.Addr) (net.Conn, func(phone uint64, serialNum uint16, id uint16, body codec.Body) *codec.Message) {
+	conn, err := net.Dial("tcp", addr.String())
+	assert.Equal(t, nil, err)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	c, _ := codec.NewCodec(nil)
+	fr := codec.NewFrameReader(conn, c)
+	return conn, func(phone uint64, serialNum uint16, id uint16, body codec.Body) *codec.Message {
+		t.Helper()
+
+		data, err := c.Encode(&codec.Message{
+			H: &codec.Header{MessageId: id, Phone: phone, SerialNum: serialNum},
+			B: body,
+		})
+		assert.Equal(t, nil, err)
+		conn.Write(data)
+
+		resp, err := fr.ReadMessage()
+		assert.Equal(t, nil, err)
+		return resp
+	}
+}
+
+func result(resp *codec.Message) uint8 {
+	return resp.B.(*codec.ServerResponse).Result
+}
+
+func TestServer_Authenticator(t *testing.T) {
+	srv, addr, cancel, _ := startServer(t, func(srv *Server) {
+		srv.Authenticator = NewMemoryAuthenticator()
+	})
+	defer cancel()
+
+	var handled = make(chan uint16, 10)
+	srv.HandleFunc(0x0f01, func(s *Session, msg *codec.Message) {
+		handled <- msg.H.SerialNum
+		s.Reply(msg, codec.ResultSuccess)
+	})
+
+	conn, roundTrip := dialTerminal(t, addr)
+	defer conn.Close()
+
+	const phone = 19161017001
+
+	// rejected before auth
+	resp := roundTrip(phone, 0, 0x0f01, &codec.RawBody{})
+	assert.Equal(t, uint64(phone), resp.H.Phone)
+	assert.Equal(t, codec.ResultFailure, result(resp))
+
+	resp = roundTrip(phone, 1, codec.MessageIdTerminalRegister, &codec.RegisterMsgBody{TerminalId: "0000001"})
+	assert.Equal(t, codec.MessageIdRegisterResponse, resp.H.MessageId)
+	reg := resp.B.(*codec.RegisterResponse)
+	assert.Equal(t, uint16(1), reg.SerialNum)
+	assert.Equal(t, codec.RegisterResultSuccess, reg.Result)
+
+	assert.Equal(t, codec.ResultFailure, result(roundTrip(phone, 2, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: "wrong"})))
+	// not routed to before auth
+	_, ok := srv.Session(phone)
+	assert.Equal(t, false, ok)
+
+	assert.Equal(t, codec.ResultSuccess, result(roundTrip(phone, 3, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: reg.AuthCode})))
+	sess, ok := srv.Session(phone)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, sess.Authenticated())
+
+	assert.Equal(t, codec.ResultSuccess, result(roundTrip(phone, 4, 0x0f01, &codec.RawBody{})))
+	assert.Equal(t, uint16(4), <-handled)
+	assert.Equal(t, 0, len(handled))
+}
+
+func TestServer_AuthenticatorPhones(t *testing.T) {
+	var a = NewMemoryAuthenticator()
+	a.SetAuthCode(111, "secret-of-111")
+	a.SetAuthCode(222, "secret-of-222")
+
+	srv, addr, cancel, _ := startServer(t, func(srv *Server) {
+		srv.Authenticator = a
+	})
+	defer cancel()
+
+	var handled = make(chan uint64, 10)
+	srv.HandleFunc(0x0f01, func(s *Session, msg *codec.Message) {
+		handled <- msg.H.Phone
+		s.Reply(msg, codec.ResultSuccess)
+	})
+
+	victim, victimTrip := dialTerminal(t, addr)
+	defer victim.Close()
+	assert.Equal(t, codec.ResultSuccess, result(victimTrip(222, 0, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: "secret-of-222"})))
+	sess, _ := srv.Session(222)
+
+	conn, roundTrip := dialTerminal(t, addr)
+	defer conn.Close()
+
+	// registering a registered phone does not leak its code
+	resp := roundTrip(222, 0, codec.MessageIdTerminalRegister, &codec.RegisterMsgBody{TerminalId: "0000002"})
+	assert.Equal(t, &codec.RegisterResponse{Result: codec.RegisterResultTerminalRegistered}, resp.B)
+
+	// frames of an unauthenticated connection do not take the victim's place
+	assert.Equal(t, codec.ResultFailure, result(roundTrip(222, 1, 0x0f01, &codec.RawBody{})))
+	cur, _ := srv.Session(222)
+	assert.Equal(t, sess, cur)
+
+	// authenticated as 111, frames of other phones are rejected
+	assert.Equal(t, codec.ResultSuccess, result(roundTrip(111, 2, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: "secret-of-111"})))
+	assert.Equal(t, codec.ResultFailure, result(roundTrip(222, 3, 0x0f01, &codec.RawBody{})))
+	assert.Equal(t, codec.ResultFailure, result(roundTrip(222, 4, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: "secret-of-222"})))
+	assert.Equal(t, codec.ResultSuccess, result(roundTrip(111, 5, 0x0f01, &codec.RawBody{})))
+	assert.Equal(t, uint64(111), <-handled)
+	assert.Equal(t, 0, len(handled))
+
+	cur, _ = srv.Session(222)
+	assert.Equal(t, sess, cur)
+	assert.Equal(t, codec.ResultSuccess, result(victimTrip(222, 1, 0x0f01, &codec.RawBody{})))
+	assert.Equal(t, uint64(222), <-handled)
+}
+
+func TestFileAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jtt808")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "auth.json")
+
+	a, err := NewFileAuthenticator(path)
+	assert.Equal(t, nil, err)
+	authCode, result, err := a.Register(19161017001, &codec.RegisterMsgBody{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, codec.RegisterResultSuccess, result)
+	assert.Equal(t, nil, a.SetAuthCode(13800000000, "provisioned"))
+
+	again, result, err := a.Register(19161017001, &codec.RegisterMsgBody{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, codec.RegisterResultTerminalRegistered, result)
+	assert.Equal(t, "", again)
+
+	// a terminal that lost its code registers again once it is revoked
+	assert.Equal(t, nil, a.Revoke(13800000000))
+	reissued, result, err := a.Register(13800000000, &codec.RegisterMsgBody{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, codec.RegisterResultSuccess, result)
+
+	a, err = NewFileAuthenticator(path)
+	assert.Equal(t, nil, err)
+	for phone, code := range map[uint64]string{19161017001: authCode, 13800000000: reissued} {
+		ok, err := a.Verify(phone, code)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, ok)
+	}
+	ok, _ := a.Verify(13800000000, "provisioned")
+	assert.Equal(t, false, ok)
+}
+
+type failingAuthenticator struct {
+}
+
+func (failingAuthenticator) Register(phone uint64, body *codec.RegisterMsgBody) (string, uint8, error) {
+	return "", 0, errors.New("register failed")
+}
+
+func (failingAuthenticator) Verify(phone uint64, authCode string) (bool, error) {
+	return false, errors.New("verify failed")
+}
+
+func TestServer_AuthenticatorError(t *testing.T) {
+	_, addr, cancel, _ := startServer(t, func(srv *Server) {
+		srv.Authenticator = failingAuthenticator{}
+	})
+	defer cancel()
+
+	conn, roundTrip := dialTerminal(t, addr)
+	defer conn.Close()
+
+	// the terminal is answered instead of left waiting
+	resp := roundTrip(111, 0, codec.MessageIdTerminalRegister, &codec.RegisterMsgBody{TerminalId: "0000001"})
+	assert.Equal(t, codec.ResultFailure, result(resp))
+	resp = roundTrip(111, 1, codec.MessageIdTerminalAuth, &codec.AuthMsgBody{AuthCode: "secret"})
+	assert.Equal(t, codec.ResultFailure, result(resp))
+}
@@ -0,0 +1,52 @@
+/*
+A server example
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	jtt808 "github.com/sceneryback/jtt808/codec"
+	"github.com/sceneryback/jtt808/server"
+)
+
+var port int
+var authFile string
+
+func init() {
+	flag.IntVar(&port, "p", 9090, "tcp port, default 9090")
+	flag.StringVar(&authFile, "auth", "", "auth codes file, terminals must register and authenticate if set")
+}
+
+func main() {
+	flag.Parse()
+
+	codec, _ := jtt808.NewCodec(nil)
+
+	srv := server.NewServer(codec)
+	if authFile != "" {
+		a, err := server.NewFileAuthenticator(authFile)
+		if err != nil {
+			fmt.Println("failed to load auth codes:", err)
+			return
+		}
+		srv.Authenticator = a
+	}
+	srv.HandleFunc(jtt808.MessageIdLocationReport, func(s *server.Session, msg *jtt808.Message) {
+		fmt.Println(msg.Human())
+		s.Reply(msg, jtt808.ResultSuccess)
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fmt.Println("listen on :", port)
+	err := srv.ListenAndServe(ctx, fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil && err != context.Canceled {
+		fmt.Println("failed to serve tcp:", err)
+	}
+}
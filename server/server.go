@@ -1,116 +1,176 @@
 /*
-A server example
+Package server is a JT/T808 tcp server, every terminal connection is served
+as a Session and decoded messages are routed to handlers by message id.
 */
-package main
+package server
 
 import (
-	"encoding/hex"
-	"flag"
-	"fmt"
-	"io"
+	"context"
+	"errors"
+	"log"
 	"net"
+	"sync"
+	"time"
 
-	jtt808 "github.com/sceneryback/jtt808/codec"
+	"github.com/sceneryback/jtt808/codec"
 )
 
-var port int
-var codec jtt808.Codec
+var (
+	ErrSessionClosed = errors.New("session closed")
+)
+
+// HandlerFunc handles a decoded message. Messages of one session are handled
+// one at a time, in the order they arrived.
+type HandlerFunc func(s *Session, msg *codec.Message)
+
+// Server serves terminal connections, its exported fields must be set before
+// calling Serve.
+type Server struct {
+	// ErrorLog logs connection and frame errors, the log package's standard
+	// logger is used if nil
+	ErrorLog *log.Logger
+
+	// Authenticator, if set, answers terminal register and auth messages, and
+	// rejects any other message until the session is authenticated. Sessions
+	// are then found by phone, see Session(), only once authenticated.
+	Authenticator Authenticator
+
+	// IdleTimeout closes sessions that sent nothing, not even a heartbeat
+	// (0x0002), for this long. Zero means no timeout.
+	IdleTimeout time.Duration
+
+	codec codec.Codec
 
-func init() {
-	flag.IntVar(&port, "p", 9090, "tcp port, default 9090")
+	mu       sync.RWMutex
+	handlers map[uint16]HandlerFunc
+	sessions map[uint64]*Session
+	conns    map[*Session]struct{}
 
-	codec, _ = jtt808.NewCodec(nil)
+	wg sync.WaitGroup
 }
 
-func handleSingleMessage(conn net.Conn, data []byte) {
-	var resp = jtt808.Message{
-		H: &jtt808.Header{
-			MessageId: 0x8001,
-			Attr: &jtt808.BodyAttr{
-				BodyLength: 5,
-			},
-		},
+func NewServer(c codec.Codec) *Server {
+	return &Server{
+		codec:    c,
+		handlers: make(map[uint16]HandlerFunc),
+		sessions: make(map[uint64]*Session),
+		conns:    make(map[*Session]struct{}),
 	}
-	var respBody = jtt808.ServerResponse{}
+}
 
-	defer func() {
-		resp.B = &respBody
-		respBytes, _ := codec.Encode(&resp)
-		conn.Write(respBytes)
-	}()
+// HandleFunc routes messages with id to h. Messages without a handler are
+// answered with a successful 0x8001.
+func (s *Server) HandleFunc(id uint16, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	msg, err := codec.Decode(data)
+	s.handlers[id] = h
+}
 
-	if msg != nil && msg.H != nil {
-		respBody.ID = msg.H.MessageId
-		respBody.SerialNum = msg.H.SerialNum
-	}
+// Session returns the session of the terminal with phone
+func (s *Server) Session(phone uint64) (*Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
+	sess, ok := s.sessions[phone]
+	return sess, ok
+}
+
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		fmt.Printf("failed to decode: %s\n", err)
-		respBody.Result = 0x01
-		return
+		return err
 	}
-	fmt.Println(msg.Human())
-
-	respBody.Result = 0x0
+	return s.Serve(ctx, ln)
 }
 
-func serveConn(conn net.Conn) {
-	defer conn.Close()
+// Serve accepts connections on ln until ctx is done, then closes ln and all
+// sessions, waits for them to finish and returns ctx.Err().
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var stopped = make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			ln.Close()
+		case <-stopped:
+		}
+	}()
 
-	fmt.Printf("received conn from %s\n", conn.RemoteAddr().String())
+	defer s.wg.Wait()
 
-	var allDataBytes []byte
-	var buff = make([]byte, 1)
-	var readStartTag = false
 	for {
-		_, err := conn.Read(buff)
+		conn, err := ln.Accept()
 		if err != nil {
-			if err == io.EOF {
-				fmt.Printf("EOF: %s\n", err)
-				return
+			s.closeSessions()
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			fmt.Printf("read failed: %s\n", err)
-			return
-		}
-		if !readStartTag {
-			// catch start
-			if buff[0] == 0x7e {
-				readStartTag = true
-			} else {
-				continue
-			}
-		} else {
-			// catch end
-			if buff[0] == 0x7e {
-				fmt.Printf("received jtt808 message: 7e%s7e\n", hex.EncodeToString(allDataBytes))
-				go handleSingleMessage(conn, allDataBytes)
-				allDataBytes = allDataBytes[:0]
-				readStartTag = false
-				continue
-			}
-			allDataBytes = append(allDataBytes, buff[0])
+			return err
 		}
+
+		sess := newSession(s, conn)
+
+		s.mu.Lock()
+		s.conns[sess] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sess.serve()
+		}()
 	}
 }
 
-func main() {
-	flag.Parse()
+func (s *Server) closeSessions() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: port})
-	if err != nil {
-		fmt.Println("failed to listen tcp:", err)
-		return
+	for sess := range s.conns {
+		sess.Close()
 	}
-	fmt.Println("listen on :",port)
+}
 
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			continue
-		}
+// bind keys sess by phone instead of prev, an older session of the same
+// terminal is closed
+func (s *Server) bind(sess *Session, prev, phone uint64) {
+	s.mu.Lock()
+	if cur, ok := s.sessions[prev]; ok && cur == sess {
+		delete(s.sessions, prev)
+	}
+	old, ok := s.sessions[phone]
+	s.sessions[phone] = sess
+	s.mu.Unlock()
 
-		go serveConn(conn)
+	if ok && old != sess {
+		old.Close()
+	}
+}
+
+func (s *Server) unbind(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, sess)
+	if cur, ok := s.sessions[sess.Phone()]; ok && cur == sess {
+		delete(s.sessions, sess.Phone())
+	}
+}
+
+func (s *Server) handler(id uint16) (HandlerFunc, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok := s.handlers[id]
+	return h, ok
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
 	}
+	log.Printf(format, args...)
 }
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/sceneryback/jtt808/codec"
+)
+
+// startServer serves on a random local port, setup runs before serving
+func startServer(t *testing.T, setup func(*Server)) (*Server, net.Addr, context.CancelFunc, chan error) {
+	c, _ := codec.NewCodec(nil)
+	srv := NewServer(c)
+	if setup != nil {
+		setup(srv)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var served = make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, ln)
+	}()
+
+	return srv, ln.Addr(), cancel, served
+}
+
+func TestServer_HandleFunc(t *testing.T) {
+	srv, addr, cancel, served := startServer(t, nil)
+
+	var handled = make(chan uint16, 10)
+	srv.HandleFunc(0x0f01, func(s *Session, msg *codec.Message) {
+		handled <- msg.H.SerialNum
+		s.Reply(msg, codec.ResultFailure)
+	})
+
+	conn, err := net.Dial("tcp", addr.String())
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	c, _ := codec.NewCodec(nil)
+	for i, id := range []uint16{0x0f01, 0x0f02, 0x0f01} {
+		data, err := c.Encode(&codec.Message{
+			H: &codec.Header{MessageId: id, Phone: 19161017001, SerialNum: uint16(i)},
+			B: &codec.RawBody{},
+		})
+		assert.Equal(t, nil, err)
+		conn.Write(data)
+	}
+
+	// responses go out in order, routed or not
+	fr := codec.NewFrameReader(conn, c)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	for i, result := range []uint8{codec.ResultFailure, codec.ResultSuccess, codec.ResultFailure} {
+		resp, err := fr.ReadMessage()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, codec.MessageIdServerResponse, resp.H.MessageId)
+		assert.Equal(t, uint64(19161017001), resp.H.Phone)
+		assert.Equal(t, uint16(i), resp.H.SerialNum)
+		assert.Equal(t, &codec.ServerResponse{SerialNum: uint16(i), ID: uint16(0x0f01 + i%2), Result: result}, resp.B)
+	}
+	assert.Equal(t, uint16(0), <-handled)
+	assert.Equal(t, uint16(2), <-handled)
+
+	sess, ok := srv.Session(19161017001)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(19161017001), sess.Phone())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-served)
+
+	_, ok = srv.Session(19161017001)
+	assert.Equal(t, false, ok)
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	srv, addr, cancel, _ := startServer(t, func(srv *Server) {
+		srv.IdleTimeout = time.Millisecond * 200
+	})
+	defer cancel()
+
+	conn, err := net.Dial("tcp", addr.String())
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	c, _ := codec.NewCodec(nil)
+	fr := codec.NewFrameReader(conn, c)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	for i := 0; i < 3; i++ {
+		data, _ := c.Encode(&codec.Message{
+			H: &codec.Header{MessageId: codec.MessageIdHeartbeat, Phone: 19161017001, SerialNum: uint16(i)},
+			B: &codec.HeartbeatBody{},
+		})
+		conn.Write(data)
+
+		resp, err := fr.ReadMessage()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, codec.MessageIdHeartbeat, resp.B.(*codec.ServerResponse).ID)
+
+		sess, ok := srv.Session(19161017001)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, true, time.Since(sess.LastActive()) < srv.IdleTimeout)
+
+		time.Sleep(srv.IdleTimeout / 2)
+	}
+
+	// closed once heartbeats stop
+	_, err = fr.ReadMessage()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSession_SetTimeZone(t *testing.T) {
+	var timestamps = make(chan time.Time, 2)
+	_, addr, cancel, _ := startServer(t, func(srv *Server) {
+		srv.HandleFunc(codec.MessageIdLocationReport, func(s *Session, msg *codec.Message) {
+			timestamps <- msg.B.(*codec.LocationMsgBody).Basic.Timestamp
+			s.SetTimeZone(time.UTC)
+		})
+	})
+	defer cancel()
+
+	conn, err := net.Dial("tcp", addr.String())
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	c, _ := codec.NewCodec(nil)
+	var ts = time.Date(2016, 10, 17, 10, 27, 56, 0, codec.DefaultTimeZone)
+	for i := 0; i < 2; i++ {
+		data, _ := c.Encode(&codec.Message{
+			H: &codec.Header{MessageId: codec.MessageIdLocationReport, Phone: 19161017001},
+			B: &codec.LocationMsgBody{Basic: &codec.BasicInfo{Timestamp: ts}},
+		})
+		conn.Write(data)
+	}
+
+	assert.Equal(t, ts.Unix(), (<-timestamps).Unix())
+	assert.Equal(t, ts.Unix()+8*3600, (<-timestamps).Unix())
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sceneryback/jtt808/codec"
+)
+
+const (
+	sessionQueueSize = 64
+)
+
+// Session is a terminal connection. Outgoing messages are written by a single
+// goroutine, in the order they were sent.
+type Session struct {
+	server *Server
+	conn   net.Conn
+
+	mu            sync.Mutex
+	codec         codec.Codec
+	timeZone      *time.Location
+	vendor        string
+	phone         uint64
+	serialNum     uint16
+	authenticated bool
+	lastActive    time.Time
+	// phone of the last message, replies go to it until phone is set
+	peer uint64
+	// header version of the last message, replies use the same
+	version         string
+	protocolVersion uint8
+
+	sendMu    sync.Mutex
+	out       chan [][]byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSession(s *Server, conn net.Conn) *Session {
+	return &Session{
+		server: s,
+		conn:   conn,
+		codec:  s.codec,
+		out:    make(chan [][]byte, sessionQueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Phone returns the terminal phone number, 0 until the first message arrives
+// or, with an Authenticator, until the terminal authenticates
+func (s *Session) Phone() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.phone
+}
+
+// Authenticated reports whether the terminal passed 0x0102 auth
+func (s *Session) Authenticated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.authenticated
+}
+
+// LastActive returns when the last message, e.g. a heartbeat, arrived
+func (s *Session) LastActive() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastActive
+}
+
+// SetTimeZone overrides the server codec's time zone of BCD timestamps for
+// this terminal
+func (s *Session) SetTimeZone(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timeZone = loc
+	s.codec = s.deriveCodec()
+}
+
+// SetVendor decodes location additional infos of this terminal with the
+// decoders registered for vendor, see codec.Codec.WithVendor
+func (s *Session) SetVendor(vendor string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.vendor = vendor
+	s.codec = s.deriveCodec()
+}
+
+// deriveCodec must be called with s.mu held
+func (s *Session) deriveCodec() codec.Codec {
+	c := s.server.codec
+	if s.timeZone != nil {
+		c = c.WithTimeZone(s.timeZone)
+	}
+	if s.vendor != "" {
+		c = c.WithVendor(s.vendor)
+	}
+	return c
+}
+
+func (s *Session) getCodec() codec.Codec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.codec
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Send encodes msg with the session's phone and next serial number, and
+// queues it for writing. Headers without a version get the terminal's. Bodies
+// longer than codec.MaxBodyLength are sent segmented.
+func (s *Session) Send(msg *codec.Message) error {
+	// serial nums are taken in the order messages are queued
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	var header = *msg.H
+	s.mu.Lock()
+	header.Phone = s.phone
+	if header.Phone == 0 {
+		header.Phone = s.peer
+	}
+	header.SerialNum = s.serialNum
+	if header.Version == codec.VersionAuto {
+		header.Version = s.version
+		header.ProtocolVersion = s.protocolVersion
+	}
+	s.mu.Unlock()
+
+	frames, err := s.getCodec().EncodeSegmented(&codec.Message{H: &header, B: msg.B}, 0)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.serialNum += uint16(len(frames))
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return ErrSessionClosed
+	default:
+	}
+
+	select {
+	case s.out <- frames:
+		return nil
+	case <-s.done:
+		return ErrSessionClosed
+	}
+}
+
+// Reply answers req with a platform general response (0x8001)
+func (s *Session) Reply(req *codec.Message, result uint8) error {
+	return s.Send(&codec.Message{
+		H: &codec.Header{
+			MessageId: codec.MessageIdServerResponse,
+			Attr:      &codec.BodyAttr{},
+		},
+		B: &codec.ServerResponse{
+			SerialNum: req.H.SerialNum,
+			ID:        req.H.MessageId,
+			Result:    result,
+		},
+	})
+}
+
+// Close closes the connection once the messages already sent are written
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func (s *Session) serve() {
+	var written = make(chan struct{})
+	go func() {
+		defer close(written)
+		s.writeLoop()
+	}()
+
+	s.readLoop()
+
+	s.Close()
+	<-written
+	s.server.unbind(s)
+}
+
+func (s *Session) writeLoop() {
+	defer s.conn.Close()
+
+	for {
+		select {
+		case frames := <-s.out:
+			if err := s.write(frames); err != nil {
+				s.server.logf("jtt808: write to %s failed: %s", s.RemoteAddr(), err)
+				s.Close()
+				return
+			}
+		case <-s.done:
+			// flush what has been queued so far
+			for {
+				select {
+				case frames := <-s.out:
+					if err := s.write(frames); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Session) write(frames [][]byte) error {
+	for _, frame := range frames {
+		if _, err := s.conn.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMessage decodes with the session's codec, which may change between
+// messages
+func (s *Session) readMessage(fr *codec.FrameReader) (*codec.Message, error) {
+	frame, err := fr.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getCodec().Decode(frame)
+	if err != nil {
+		return nil, &codec.FrameError{Frame: frame, Err: err}
+	}
+	return msg, nil
+}
+
+func (s *Session) readLoop() {
+	var fr = codec.NewFrameReader(s.conn, nil)
+	for {
+		if timeout := s.server.IdleTimeout; timeout > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+
+		msg, err := s.readMessage(fr)
+		if err != nil {
+			if _, ok := err.(*codec.FrameError); ok {
+				s.server.logf("jtt808: skipped frame from %s: %s", s.RemoteAddr(), err)
+				continue
+			}
+			select {
+			case <-s.done:
+			default:
+				if err != io.EOF {
+					s.server.logf("jtt808: read from %s failed: %s", s.RemoteAddr(), err)
+				}
+			}
+			return
+		}
+
+		// with an Authenticator, sessions are bound to a phone once
+		// authenticated, see authenticate
+		var a = s.server.Authenticator
+		s.mu.Lock()
+		var prev = s.phone
+		var rebind = a == nil && prev != msg.H.Phone
+		if rebind {
+			s.phone = msg.H.Phone
+		}
+		s.peer = msg.H.Phone
+		s.lastActive = time.Now()
+		s.version = msg.H.Version
+		s.protocolVersion = msg.H.ProtocolVersion
+		s.mu.Unlock()
+		if rebind {
+			s.server.bind(s, prev, msg.H.Phone)
+		}
+
+		if a != nil && !s.authorize(a, msg) {
+			continue
+		}
+
+		h, ok := s.server.handler(msg.H.MessageId)
+		if !ok {
+			s.Reply(msg, codec.ResultSuccess)
+			continue
+		}
+		h(s, msg)
+	}
+}
@@ -11,17 +11,57 @@ func EncodeBCD(s string) []byte {
 	return bs
 }
 
+// AppendBCDUint appends n as 2*size BCD digits, left padded with zeros, to
+// dst. It returns false if n has more digits.
+func AppendBCDUint(dst []byte, n uint64, size int) ([]byte, bool) {
+	var start = len(dst)
+	for i := 0; i < size; i++ {
+		dst = append(dst, 0)
+	}
+	for i := start + size - 1; i >= start; i-- {
+		dst[i] = uint8(n%10) | uint8(n/10%10)<<4
+		n /= 100
+	}
+	return dst, n == 0
+}
+
+// ParseBCDUint decodes BCD digits into a number without allocating. It
+// returns false if a nibble is not a decimal digit or the number overflows.
+func ParseBCDUint(data []byte) (uint64, bool) {
+	var n uint64
+	for _, b := range data {
+		hi, lo := b>>4, b&0x0f
+		if hi > 9 || lo > 9 {
+			return 0, false
+		}
+		next := n*100 + uint64(hi)*10 + uint64(lo)
+		if n > (1<<64-1)/100 || next < n*100 {
+			return 0, false
+		}
+		n = next
+	}
+	return n, true
+}
+
 // 0x161017102756  --  时间，6 字节，8421 码，即 2016-10-17 10:27:56
 func DecodeBCD(data []byte) string {
-	var result string
+	var result = make([]byte, 0, len(data)*2)
 
 	var part0, part1 byte
 	for i := range data {
 		part0 = (data[i] & 0xf0) >> 4
 		part1 = data[i] & 0x0f
-		result += strconv.Itoa(int(part0))
-		result += strconv.Itoa(int(part1))
+		result = appendNibble(result, part0)
+		result = appendNibble(result, part1)
 	}
 
-	return result
+	return string(result)
+}
+
+// nibbles over 9 are written as two digits
+func appendNibble(dst []byte, n byte) []byte {
+	if n > 9 {
+		return strconv.AppendInt(dst, int64(n), 10)
+	}
+	return append(dst, '0'+n)
 }
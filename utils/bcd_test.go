@@ -10,3 +10,37 @@ func TestEncodeBCD(t *testing.T) {
 	bs := EncodeBCD(s)
 	fmt.Printf("%x\n", bs)
 }
+
+func TestParseBCDUint(t *testing.T) {
+	for _, c := range []struct {
+		bcd []byte
+		n   uint64
+		ok  bool
+	}{
+		{[]byte{0x01, 0x91, 0x61, 0x01, 0x70, 0x01}, 19161017001, true},
+		{[]byte{0x00, 0x00}, 0, true},
+		{[]byte{0x1a, 0x00}, 0, false},
+		{[]byte{0x18, 0x44, 0x67, 0x44, 0x07, 0x37, 0x09, 0x55, 0x16, 0x15}, 18446744073709551615, true},
+		{[]byte{0x18, 0x44, 0x67, 0x44, 0x07, 0x37, 0x09, 0x55, 0x16, 0x16}, 0, false},
+	} {
+		n, ok := ParseBCDUint(c.bcd)
+		if n != c.n || ok != c.ok {
+			t.Errorf("ParseBCDUint(%x) = %d, %v", c.bcd, n, ok)
+		}
+
+		if !c.ok {
+			continue
+		}
+		bcd, ok := AppendBCDUint(nil, c.n, len(c.bcd))
+		if !ok || string(bcd) != string(c.bcd) {
+			t.Errorf("AppendBCDUint(%d) = %x, %v", c.n, bcd, ok)
+		}
+	}
+
+	if _, ok := AppendBCDUint(nil, 1234567, 3); ok {
+		t.Errorf("AppendBCDUint accepted 7 digits into 3 bytes")
+	}
+	if s := DecodeBCD([]byte{0x16, 0x1a}); s != "16110" {
+		t.Errorf("DecodeBCD = %s", s)
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+var ErrInvalidGBK = errors.New("invalid GBK")
+
+// EncodeGBK converts an utf-8 string to GBK, e.g. plate numbers like 京A12345
+func EncodeGBK(s string) ([]byte, error) {
+	return simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+}
+
+// DecodeGBK converts GBK bytes to an utf-8 string, bytes that are not GBK
+// fail with ErrInvalidGBK
+func DecodeGBK(data []byte) (string, error) {
+	bs, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	// the decoder replaces them with U+FFFD, which GBK has no bytes for
+	if bytes.ContainsRune(bs, utf8.RuneError) {
+		return "", ErrInvalidGBK
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestDecodeGBK(t *testing.T) {
+	for _, c := range []struct {
+		gbk []byte
+		s   string
+		err error
+	}{
+		{[]byte{0xd4, 0xc1, 0x42}, "粤B", nil},
+		{[]byte{0x41, 0x00}, "A\x00", nil},
+		{[]byte{0xff}, "", ErrInvalidGBK},
+		{[]byte{0xd4}, "", ErrInvalidGBK},
+	} {
+		s, err := DecodeGBK(c.gbk)
+		if s != c.s || err != c.err {
+			t.Errorf("DecodeGBK(%x) = %q, %v, want %q, %v", c.gbk, s, err, c.s, c.err)
+		}
+	}
+}
@@ -16,3 +16,8 @@ func MacIntegerToStr(n int64) string {
 func HexBytesToMacAddr(src []byte) string {
 	return net.HardwareAddr(src).String()
 }
+
+// MacAddrToHexBytes is the reverse of HexBytesToMacAddr
+func MacAddrToHexBytes(s string) ([]byte, error) {
+	return net.ParseMAC(s)
+}